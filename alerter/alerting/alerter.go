@@ -3,7 +3,9 @@ package alerting
 import (
 	"biocad-opcua/data"
 	"biocad-opcua/shared"
+	"fmt"
 	"log"
+	"time"
 )
 
 // Alerter listens for measurments and checks them for crossing the alerting thresholds.
@@ -13,6 +15,14 @@ type Alerter struct {
 	fanout *shared.Fanout
 	logger *log.Logger
 	stop   chan interface{}
+	// qualities are the qualities of the latest values of the parameters of the servers.
+	qualities map[string]qualityState
+}
+
+// qualityState is the quality of the latest value of the parameter.
+type qualityState struct {
+	bad       bool
+	timestamp time.Time
 }
 
 // Start starts listening for new measurments to check them for crossing alerting thresholds.
@@ -21,15 +31,21 @@ func (alerter *Alerter) Start() {
 		for {
 			select {
 			case measure := <-alerter.source:
-				params, ok := measure.(data.ParametersState)
+				switch mes := measure.(type) {
+				case data.ParametersState:
+					// The quality transitions are tracked in the order the measurements arrive.
+					alerter.checkQualityForAlerts(mes)
+					go alerter.checkParametersForAlerts(mes)
 
-				if !ok {
-					alerter.logger.Println("Type assertion failed")
+				case data.StalenessEvent:
+					alerter.checkStalenessForAlerts(mes)
 
-					continue
-				}
+				case data.ConnectionEvent, data.Alert, data.WriteAudit, data.ServerDiagnostics:
+					// Connection events, alerts, audit entries and diagnostics carry no values to check.
 
-				go alerter.checkParametersForAlerts(params)
+				default:
+					alerter.logger.Println("Type assertion failed")
+				}
 
 			case <-alerter.stop:
 				alerter.logger.Println("Alerter stopped")
@@ -65,17 +81,36 @@ func (alerter *Alerter) Stop() {
 // NewAlerter creates a new alerter to warn about alerting thresholds crossing.
 func NewAlerter(cache *shared.Cache, logger *log.Logger) *Alerter {
 	return &Alerter{
-		cache:  cache,
-		source: make(chan data.Measurement),
-		fanout: shared.NewFanout(),
-		logger: logger,
-		stop:   make(chan interface{}),
+		cache:     cache,
+		source:    make(chan data.Measurement),
+		fanout:    shared.NewFanout(),
+		logger:    logger,
+		stop:      make(chan interface{}),
+		qualities: make(map[string]qualityState),
 	}
 }
 
 func (alerter *Alerter) checkParametersForAlerts(params data.ParametersState) {
-	for parameter, value := range params.Parameters {
-		bounds, err := alerter.cache.GetParameterBounds(parameter)
+	for parameter, sample := range params.Parameters {
+		timestamp := sample.SourceTimestamp
+
+		if timestamp.IsZero() {
+			timestamp = params.Timestamp
+		}
+
+		// Bad values are not compared against the bounds.
+		if sample.Quality.IsBad() {
+			continue
+		}
+
+		// Only numeric values can cross the alerting thresholds.
+		value, ok := sample.Float()
+
+		if !ok {
+			continue
+		}
+
+		bounds, err := alerter.cache.GetParameterBounds(params.Server, parameter)
 
 		if err != nil {
 			continue
@@ -84,14 +119,16 @@ func (alerter *Alerter) checkParametersForAlerts(params data.ParametersState) {
 		// Check if the alerting thresholds for the parameter are crossed.
 		if value < bounds.LowerBound || value > bounds.UpperBound {
 			alert := data.Alert{
+				Server:    params.Server,
 				Parameter: parameter,
+				Type:      data.AlertThreshold,
 				Bounds:    bounds,
 				Value:     value,
-				Timestamp: params.Timestamp,
+				Timestamp: timestamp,
 			}
 
-			alerter.logger.Printf("Alert occured:\nTime: %v\nParameter: %s\nLower bound: %f\n"+
-				"Upper bound: %f\nValue: %f\n", alert.Timestamp, alert.Parameter, alert.LowerBound,
+			alerter.logger.Printf("Alert occured:\nTime: %v\nServer: %s\nParameter: %s\nLower bound: %f\n"+
+				"Upper bound: %f\nValue: %f\n", alert.Timestamp, alert.Server, alert.Parameter, alert.LowerBound,
 				alert.UpperBound, alert.Value)
 
 			alerter.fanout.SendMeasurement(alert)
@@ -99,6 +136,86 @@ func (alerter *Alerter) checkParametersForAlerts(params data.ParametersState) {
 	}
 }
 
+// checkQualityForAlerts raises the quality alert when the quality of the parameter
+// value turns bad and when it recovers rather than on every bad value.
+func (alerter *Alerter) checkQualityForAlerts(params data.ParametersState) {
+	for parameter, sample := range params.Parameters {
+		timestamp := sample.SourceTimestamp
+
+		if timestamp.IsZero() {
+			timestamp = params.Timestamp
+		}
+
+		if !alerter.qualityChanged(params.Server, parameter, sample.Quality.IsBad(), timestamp) {
+			continue
+		}
+
+		alerter.raiseQualityAlert(params.Server, parameter, sample, timestamp)
+	}
+}
+
+// qualityChanged remembers whether the latest value of the parameter had the bad quality
+// and returns true if it differs from the quality of the previous value. The measurements
+// may arrive out of order, so the values older than the latest one are ignored.
+// The quality of the parameter is considered good until the first value arrives.
+func (alerter *Alerter) qualityChanged(server, parameter string, bad bool, timestamp time.Time) bool {
+	key := server + "/" + parameter
+	state := alerter.qualities[key]
+
+	if timestamp.Before(state.timestamp) {
+		return false
+	}
+
+	alerter.qualities[key] = qualityState{bad: bad, timestamp: timestamp}
+
+	return state.bad != bad
+}
+
+// raiseQualityAlert warns that the server reported the bad quality of the parameter value
+// or that the quality has recovered. The alert is active while the quality is bad.
+func (alerter *Alerter) raiseQualityAlert(server, parameter string, sample data.Sample, timestamp time.Time) {
+	alert := data.Alert{
+		Server:    server,
+		Parameter: parameter,
+		Type:      data.AlertQuality,
+		Message:   fmt.Sprintf("%s (0x%08X)", sample.Quality.Status, sample.Quality.StatusCode),
+		Timestamp: timestamp,
+		Active:    sample.Quality.IsBad(),
+	}
+
+	alert.Value, _ = sample.Float()
+
+	if alert.Active {
+		alerter.logger.Printf("Quality alert occured:\nTime: %v\nServer: %s\nParameter: %s\nStatus: %s\n",
+			alert.Timestamp, alert.Server, alert.Parameter, alert.Message)
+	} else {
+		alerter.logger.Printf("Quality restored:\nTime: %v\nServer: %s\nParameter: %s\nStatus: %s\n",
+			alert.Timestamp, alert.Server, alert.Parameter, alert.Message)
+	}
+
+	alerter.fanout.SendMeasurement(alert)
+}
+
+// checkStalenessForAlerts warns that the parameter has stopped receiving updates.
+func (alerter *Alerter) checkStalenessForAlerts(event data.StalenessEvent) {
+	if !event.Stale {
+		return
+	}
+
+	alert := data.Alert{
+		Server:    event.Server,
+		Parameter: event.Parameter,
+		Type:      data.AlertNoData,
+		Message:   fmt.Sprintf("No data since %s", event.LastUpdate.Format(time.RFC3339)),
+		Timestamp: event.Timestamp,
+	}
+
+	alerter.logger.Printf("No data alert occured:\nTime: %v\nServer: %s\nParameter: %s\nLast update: %v\n",
+		alert.Timestamp, alert.Server, alert.Parameter, event.LastUpdate)
+
+	alerter.fanout.SendMeasurement(alert)
+}
+
 func (alerter *Alerter) handleRemoveSubscriberError(err error) {
 	if err != nil {
 		alerter.logger.Println("Couldn't remove the subscriber:", err)
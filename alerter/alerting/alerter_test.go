@@ -0,0 +1,35 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQualityChangedOutOfOrder(t *testing.T) {
+	alerter := &Alerter{qualities: make(map[string]qualityState)}
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		bad     bool
+		second  int
+		changed bool
+	}{
+		{bad: false, second: 0, changed: false},
+		{bad: true, second: 2, changed: true},
+		// The good value older than the bad one arrives late and is ignored.
+		{bad: false, second: 1, changed: false},
+		{bad: true, second: 3, changed: false},
+		{bad: false, second: 4, changed: true},
+		// The bad value older than the good one arrives late and is ignored.
+		{bad: true, second: 3, changed: false},
+	}
+
+	for _, test := range tests {
+		timestamp := started.Add(time.Duration(test.second) * time.Second)
+		changed := alerter.qualityChanged("server", "Temperature", test.bad, timestamp)
+
+		if changed != test.changed {
+			t.Errorf("qualityChanged(bad %t at %ds) = %t, want %t", test.bad, test.second, changed, test.changed)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"biocad-opcua/alerter/alerting"
-	"biocad-opcua/data"
 	"biocad-opcua/shared"
 	"flag"
 	"io"
@@ -97,22 +96,28 @@ func main() {
 	handleError(logger, "Couldn't connect to the message broker", err)
 	defer subscriber.CloseConnection()
 
+	// Create a publisher to spread the alerts along with the alarms of the servers.
+	pb := shared.NewPublisher(brokerAddress, topic, logger)
+	err = pb.Connect()
+	handleError(logger, "Couldn't connect to the message broker", err)
+	defer pb.CloseConnection()
+	pb.Start()
+	defer pb.Stop()
+
 	// Check for new parameters and set the default bounds for them.
 	parameters, err := cache.GetAllParameters()
 	handleError(logger, "Couldn't get a list of parameters from the cache", err)
 
-	for _, parameter := range parameters {
-		exists, err := cache.CheckParameterBoundsExist(parameter)
-		handleError(logger, "Couldn't check if the parameter exists in the cache", err)
-
-		if !exists {
-			err = cache.SetParameterBounds(parameter, data.DefaultBounds())
+	for server, names := range parameters {
+		for _, parameter := range names {
+			_, err := cache.SetDefaultParameterBounds(server, parameter)
 			handleError(logger, "Couldn't set the default bounds for the parameter", err)
 		}
 	}
 
 	// Channel subscriptions.
 	alerter.AddChannelSubscriber(dbChannel)
+	alerter.AddChannelSubscriber(pb.GetChannel())
 	alerter.Start()
 	defer alerter.Stop()
 
@@ -121,7 +126,7 @@ func main() {
 	defer subscriber.Stop()
 
 	// Interrupt.
-	interrupt := make(chan os.Signal)
+	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Kill, os.Interrupt)
 
 	<-interrupt
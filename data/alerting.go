@@ -6,12 +6,34 @@ import (
 	influxdb "github.com/influxdata/influxdb1-client/v2"
 )
 
+// Types of the alerts.
+const (
+	// AlertThreshold is raised when the parameter value crosses the alerting bounds.
+	AlertThreshold = "threshold"
+	// AlertQuality is raised when the quality of the parameter value reported by the server
+	// turns bad (the alert is active) and when it recovers (the alert is inactive).
+	AlertQuality = "quality"
+	// AlertEvent is an alarm or an event raised by the OPC UA server itself.
+	AlertEvent = "event"
+	// AlertNoData is raised when the parameter hasn't been updated for longer than its maximum age.
+	AlertNoData = "nodata"
+)
+
 // Alert represents an alert message for a certain parameter.
 type Alert struct {
+	Server    string
 	Parameter string
+	Type      string
 	Bounds
 	Value     float64
+	Message   string
 	Timestamp time.Time
+	// The fields below are set for the alarms raised by the server only.
+	EventType string `json:",omitempty"`
+	Condition string `json:",omitempty"`
+	Severity  uint16 `json:",omitempty"`
+	// Active is set for the alarms raised by the server and for the quality alerts.
+	Active bool `json:",omitempty"`
 }
 
 // Bounds are values the parameter should be within.
@@ -23,13 +45,27 @@ type Bounds struct {
 // ToDataPoint transforms alert object into a time-series data point.
 func (alert Alert) ToDataPoint() (*influxdb.Point, error) {
 	tags := map[string]string{
+		"server":    alert.Server,
 		"parameter": alert.Parameter,
+		"type":      alert.Type,
 	}
 
 	fields := map[string]interface{}{
 		"lower_bound": alert.LowerBound,
 		"upper_bound": alert.UpperBound,
 		"value":       alert.Value,
+		"message":     alert.Message,
+	}
+
+	if alert.Type == AlertEvent {
+		fields["event_type"] = alert.EventType
+		fields["condition"] = alert.Condition
+		fields["severity"] = int64(alert.Severity)
+		fields["active"] = alert.Active
+	}
+
+	if alert.Type == AlertQuality {
+		fields["active"] = alert.Active
 	}
 
 	point, err := influxdb.NewPoint("alerts", tags, fields, alert.Timestamp)
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"time"
+
+	influxdb "github.com/influxdata/influxdb1-client/v2"
+)
+
+// WriteAudit records a write of the parameter value to the OPC UA server,
+// including the writes rejected by the monitor or by the server.
+type WriteAudit struct {
+	Server    string
+	Parameter string
+	User      string
+	OldValue  Value
+	NewValue  Value
+	Status    string
+	// Error is the reason the monitor has rejected the write.
+	Error     string `json:",omitempty"`
+	Timestamp time.Time
+}
+
+// ToDataPoint transforms write audit object into a time-series data point.
+// The values of the parameters have different kinds while the type of the field
+// can't change, so the values are stored as strings tagged with their kinds.
+func (audit WriteAudit) ToDataPoint() (*influxdb.Point, error) {
+	tags := map[string]string{
+		"server":    audit.Server,
+		"parameter": audit.Parameter,
+		"user":      audit.User,
+	}
+
+	fields := map[string]interface{}{
+		"status": audit.Status,
+	}
+
+	if audit.Error != "" {
+		fields["error"] = audit.Error
+	}
+
+	oldValue, err := audit.OldValue.Format()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if audit.OldValue.Kind != "" {
+		tags["old_type"] = audit.OldValue.Kind
+		fields["old_value"] = oldValue
+	}
+
+	newValue, err := audit.NewValue.Format()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if audit.NewValue.Kind != "" {
+		tags["new_type"] = audit.NewValue.Kind
+		fields["new_value"] = newValue
+	}
+
+	point, err := influxdb.NewPoint("audit", tags, fields, audit.Timestamp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return point, nil
+}
@@ -0,0 +1,38 @@
+package data
+
+// WriteCommand asks the monitor to write the value to the parameter on the OPC UA server.
+type WriteCommand struct {
+	// Server is the name of the server the parameter belongs to.
+	// The server monitoring the parameter is looked up if it's empty.
+	Server    string
+	Parameter string
+	Value     Value
+	// User is the name of the user the value is written on behalf of.
+	User string
+}
+
+// CallCommand asks the monitor to call the method on the OPC UA server.
+type CallCommand struct {
+	// Server is the name of the server the method belongs to.
+	// The server allowing the method is looked up if it's empty.
+	Server    string
+	Method    string
+	Arguments []Value
+	// User is the name of the user the method is called on behalf of.
+	User string
+}
+
+// CommandResult is the reply of the monitor to a command.
+type CommandResult struct {
+	StatusCode uint32
+	Status     string
+	// Error describes why the command has been rejected by the monitor.
+	Error string `json:",omitempty"`
+	// Outputs are the output arguments of the called method.
+	Outputs []Value `json:",omitempty"`
+}
+
+// IsGood returns true if the command has been executed successfully.
+func (result CommandResult) IsGood() bool {
+	return result.Error == "" && result.StatusCode == 0
+}
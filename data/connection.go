@@ -0,0 +1,56 @@
+package data
+
+import (
+	"time"
+
+	influxdb "github.com/influxdata/influxdb1-client/v2"
+)
+
+const (
+	// ConnectionLost means the monitor has lost the connection to the OPC UA server
+	// and doesn't receive parameter updates until it reconnects.
+	ConnectionLost = "lost"
+	// ConnectionRestored means the monitor has reconnected to the OPC UA server
+	// and receives parameter updates again.
+	ConnectionRestored = "restored"
+	// ConnectionFailover means the monitor has switched to the backup OPC UA server
+	// and receives parameter updates from it.
+	ConnectionFailover = "failover"
+)
+
+// ConnectionEvent notifies about the change of the OPC UA server connection state.
+type ConnectionEvent struct {
+	Server   string
+	Endpoint string
+	// PreviousEndpoint is the endpoint of the server the monitor has switched from.
+	PreviousEndpoint string `json:",omitempty"`
+	State            string
+	Error            string
+	Timestamp        time.Time
+}
+
+// ToDataPoint transforms connection event object into a time-series data point.
+func (event ConnectionEvent) ToDataPoint() (*influxdb.Point, error) {
+	tags := map[string]string{
+		"server":   event.Server,
+		"endpoint": event.Endpoint,
+		"state":    event.State,
+	}
+
+	fields := map[string]interface{}{
+		"connected": event.State != ConnectionLost,
+		"error":     event.Error,
+	}
+
+	if event.PreviousEndpoint != "" {
+		fields["previous_endpoint"] = event.PreviousEndpoint
+	}
+
+	point, err := influxdb.NewPoint("connection", tags, fields, event.Timestamp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return point, nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"time"
+
+	influxdb "github.com/influxdata/influxdb1-client/v2"
+)
+
+// ServerDiagnostics describes the health of the link to the OPC UA server.
+type ServerDiagnostics struct {
+	Server   string
+	Endpoint string
+	// State is the state of the server, e.g. Running.
+	State string
+	// StartTime and CurrentTime are read from the clock of the server.
+	StartTime        time.Time
+	CurrentTime      time.Time
+	ProductName      string
+	ManufacturerName string
+	SoftwareVersion  string
+	BuildNumber      string
+	// RoundTrip is the round-trip time of the request reading the server status.
+	RoundTrip time.Duration
+	// NotificationsPerSecond is the rate of the values and the events received from the server.
+	NotificationsPerSecond float64
+	// KeepAlives are the keep-alive messages received since the previous diagnostics.
+	KeepAlives int
+	// MissedMessages are the notification messages lost since the previous diagnostics.
+	// They're detected by the gaps in the sequence numbers of the received ones.
+	MissedMessages int
+	// Subscription is missing in the polling mode or if the server doesn't provide its diagnostics.
+	Subscription *SubscriptionDiagnostics `json:",omitempty"`
+	Timestamp    time.Time
+}
+
+// SubscriptionDiagnostics are the counters the server keeps for the subscription of the monitor.
+// They supplement the counters of the monitor, but the servers often don't provide them.
+type SubscriptionDiagnostics struct {
+	SubscriptionID          uint32
+	PublishRequests         uint32
+	LatePublishRequests     uint32
+	RepublishRequests       uint32
+	DataChangeNotifications uint32
+	EventNotifications      uint32
+	// KeepAliveCount is the number of the publishing intervals without notifications
+	// since the last message sent to the monitor.
+	KeepAliveCount         uint32
+	UnacknowledgedMessages uint32
+	// DiscardedMessages are the notification messages dropped by the server
+	// before the monitor acknowledged them.
+	DiscardedMessages uint32
+	// QueueOverflows are the values dropped because the queues of the monitored items were full.
+	QueueOverflows     uint32
+	NextSequenceNumber uint32
+}
+
+// ToDataPoint transforms server diagnostics object into a time-series data point.
+func (diagnostics ServerDiagnostics) ToDataPoint() (*influxdb.Point, error) {
+	tags := map[string]string{
+		"server":   diagnostics.Server,
+		"endpoint": diagnostics.Endpoint,
+	}
+
+	fields := map[string]interface{}{
+		"state":                    diagnostics.State,
+		"current_time":             diagnostics.CurrentTime.UnixNano(),
+		"software_version":         diagnostics.SoftwareVersion,
+		"build_number":             diagnostics.BuildNumber,
+		"round_trip_ms":            float64(diagnostics.RoundTrip) / float64(time.Millisecond),
+		"notifications_per_second": diagnostics.NotificationsPerSecond,
+		"keep_alives":              diagnostics.KeepAlives,
+		"missed_messages":          diagnostics.MissedMessages,
+	}
+
+	if subscription := diagnostics.Subscription; subscription != nil {
+		fields["publish_requests"] = int64(subscription.PublishRequests)
+		fields["late_publish_requests"] = int64(subscription.LatePublishRequests)
+		fields["republish_requests"] = int64(subscription.RepublishRequests)
+		fields["data_change_notifications"] = int64(subscription.DataChangeNotifications)
+		fields["event_notifications"] = int64(subscription.EventNotifications)
+		fields["keep_alive_count"] = int64(subscription.KeepAliveCount)
+		fields["unacknowledged_messages"] = int64(subscription.UnacknowledgedMessages)
+		fields["discarded_messages"] = int64(subscription.DiscardedMessages)
+		fields["queue_overflows"] = int64(subscription.QueueOverflows)
+		fields["next_sequence_number"] = int64(subscription.NextSequenceNumber)
+	}
+
+	point, err := influxdb.NewPoint("diagnostics", tags, fields, diagnostics.Timestamp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return point, nil
+}
@@ -0,0 +1,45 @@
+package data
+
+// ParameterMetadata describes the parameter to the users of the system.
+type ParameterMetadata struct {
+	// Unit is the engineering unit of the parameter value, e.g. °C.
+	Unit        string `json:",omitempty"`
+	Description string `json:",omitempty"`
+	// DataType is the name of the OPC UA data type of the parameter.
+	DataType string `json:",omitempty"`
+	// EURange is the range the parameter value is expected to be within in normal operation.
+	EURange *Bounds `json:",omitempty"`
+	// InstrumentRange is the range the instrument is able to measure.
+	InstrumentRange *Bounds `json:",omitempty"`
+}
+
+// IsEmpty returns true if nothing is known about the parameter.
+func (metadata ParameterMetadata) IsEmpty() bool {
+	return metadata == ParameterMetadata{}
+}
+
+// Merge returns the metadata completed with the fields of the other metadata
+// which are missing in this one.
+func (metadata ParameterMetadata) Merge(other ParameterMetadata) ParameterMetadata {
+	if metadata.Unit == "" {
+		metadata.Unit = other.Unit
+	}
+
+	if metadata.Description == "" {
+		metadata.Description = other.Description
+	}
+
+	if metadata.DataType == "" {
+		metadata.DataType = other.DataType
+	}
+
+	if metadata.EURange == nil {
+		metadata.EURange = other.EURange
+	}
+
+	if metadata.InstrumentRange == nil {
+		metadata.InstrumentRange = other.InstrumentRange
+	}
+
+	return metadata
+}
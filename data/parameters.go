@@ -41,20 +41,43 @@ type Measurement interface {
 
 // ParametersState represents the state of the system parameters at a certain moment of time.
 type ParametersState struct {
+	Server     string
 	Timestamp  time.Time
-	Parameters map[string]float64
+	Parameters map[string]Sample
 }
 
 // ToDataPoint transforms ParametersState object into a time-series data point.
+// Besides the value, the quality, the status code and the source timestamp
+// of each parameter are stored in the fields with the corresponding suffixes.
 func (params ParametersState) ToDataPoint() (*influxdb.Point, error) {
 	fields := make(map[string]interface{})
 
-	for parameter, value := range params.Parameters {
+	for parameter, sample := range params.Parameters {
+		field, err := sample.Field()
+
+		if err != nil {
+			return nil, err
+		}
+
 		param := strings.ToLower(parameter)
-		fields[param] = value
+
+		if field != nil {
+			fields[param] = field
+		}
+
+		fields[param+"_quality"] = sample.Quality.Severity
+		fields[param+"_status"] = int64(sample.Quality.StatusCode)
+
+		if !sample.SourceTimestamp.IsZero() {
+			fields[param+"_source_time"] = sample.SourceTimestamp.UnixNano()
+		}
+	}
+
+	tags := map[string]string{
+		"server": params.Server,
 	}
 
-	point, err := influxdb.NewPoint("parameters", nil, fields, params.Timestamp)
+	point, err := influxdb.NewPoint("parameters", tags, fields, params.Timestamp)
 
 	return point, err
 }
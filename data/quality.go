@@ -0,0 +1,52 @@
+package data
+
+import "time"
+
+// Severities of the OPC UA status codes.
+const (
+	QualityGood      = "Good"
+	QualityUncertain = "Uncertain"
+	QualityBad       = "Bad"
+)
+
+// Quality is the OPC UA quality of the parameter value.
+type Quality struct {
+	Severity   string
+	StatusCode uint32
+	// Status is the name of the status code, e.g. BadSensorFailure.
+	Status string
+}
+
+// Sample is a parameter value along with its quality and timestamps
+// reported by the OPC UA server.
+type Sample struct {
+	Value
+	Quality         Quality
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+// NewQuality creates a quality object for the OPC UA status code.
+// The severity is taken from the two most significant bits of the code.
+func NewQuality(statusCode uint32, status string) Quality {
+	severity := QualityBad
+
+	switch statusCode >> 30 {
+	case 0:
+		severity = QualityGood
+
+	case 1:
+		severity = QualityUncertain
+	}
+
+	return Quality{
+		Severity:   severity,
+		StatusCode: statusCode,
+		Status:     status,
+	}
+}
+
+// IsBad checks if the value can't be used because of the bad quality.
+func (quality Quality) IsBad() bool {
+	return quality.Severity == QualityBad
+}
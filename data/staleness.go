@@ -0,0 +1,38 @@
+package data
+
+import (
+	"time"
+
+	influxdb "github.com/influxdata/influxdb1-client/v2"
+)
+
+// StalenessEvent notifies that the parameter has stopped or resumed receiving updates.
+// The parameter is stale if it hasn't been updated for longer than its maximum age.
+type StalenessEvent struct {
+	Server     string
+	Parameter  string
+	Stale      bool
+	LastUpdate time.Time
+	Timestamp  time.Time
+}
+
+// ToDataPoint transforms staleness event object into a time-series data point.
+func (event StalenessEvent) ToDataPoint() (*influxdb.Point, error) {
+	tags := map[string]string{
+		"server":    event.Server,
+		"parameter": event.Parameter,
+	}
+
+	fields := map[string]interface{}{
+		"stale":       event.Stale,
+		"last_update": event.LastUpdate.UnixNano(),
+	}
+
+	point, err := influxdb.NewPoint("staleness", tags, fields, event.Timestamp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return point, nil
+}
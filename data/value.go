@@ -0,0 +1,142 @@
+package data
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Kinds of the parameter values.
+const (
+	// KindNumber is a value of any numeric OPC UA type.
+	KindNumber = "number"
+	// KindBoolean is a value of the Boolean OPC UA type.
+	KindBoolean = "boolean"
+	// KindText is a value of the String, DateTime, LocalizedText
+	// and other OPC UA types represented as text.
+	KindText = "text"
+	// KindArray is an array of values.
+	KindArray = "array"
+)
+
+// Value is a typed value of the monitored parameter.
+// Only the field corresponding to the value kind is set. The number and the boolean
+// are always encoded, so the zero and false readings aren't lost in JSON.
+type Value struct {
+	Kind    string
+	Type    string `json:",omitempty"`
+	Number  float64
+	Boolean bool
+	Text    string  `json:",omitempty"`
+	Array   []Value `json:",omitempty"`
+}
+
+// NewNumber creates a numeric value.
+func NewNumber(number float64) Value {
+	return Value{
+		Kind:   KindNumber,
+		Number: number,
+	}
+}
+
+// NewBoolean creates a boolean value.
+func NewBoolean(boolean bool) Value {
+	return Value{
+		Kind:    KindBoolean,
+		Boolean: boolean,
+	}
+}
+
+// NewText creates a text value.
+func NewText(text string) Value {
+	return Value{
+		Kind: KindText,
+		Text: text,
+	}
+}
+
+// NewArray creates an array of values.
+func NewArray(items []Value) Value {
+	return Value{
+		Kind:  KindArray,
+		Array: items,
+	}
+}
+
+// Float returns the numeric value. The second result is false if the value is not a number.
+func (value Value) Float() (float64, bool) {
+	if value.Kind != KindNumber {
+		return 0, false
+	}
+
+	return value.Number, true
+}
+
+// Interface returns the value as a plain Go value: float64, bool, string or a slice of them.
+func (value Value) Interface() interface{} {
+	switch value.Kind {
+	case KindNumber:
+		return value.Number
+
+	case KindBoolean:
+		return value.Boolean
+
+	case KindText:
+		return value.Text
+
+	default:
+		items := make([]interface{}, len(value.Array))
+
+		for i := range value.Array {
+			items[i] = value.Array[i].Interface()
+		}
+
+		return items
+	}
+}
+
+// Field returns the value in the form it's stored in the time-series database.
+// Arrays are stored as JSON strings. The result is nil for an empty value.
+func (value Value) Field() (interface{}, error) {
+	if value.Kind == "" {
+		return nil, nil
+	}
+
+	if value.Kind != KindArray {
+		return value.Interface(), nil
+	}
+
+	bytes, err := json.Marshal(value.Interface())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return string(bytes), nil
+}
+
+// Format returns the value as a string regardless of its kind, so the values of different
+// kinds can be stored in the same field. Numbers are formatted in the shortest form,
+// arrays as JSON. The result is empty for an empty value.
+func (value Value) Format() (string, error) {
+	switch value.Kind {
+	case "":
+		return "", nil
+
+	case KindNumber:
+		return strconv.FormatFloat(value.Number, 'g', -1, 64), nil
+
+	case KindBoolean:
+		return strconv.FormatBool(value.Boolean), nil
+
+	case KindText:
+		return value.Text, nil
+	}
+
+	bytes, err := json.Marshal(value.Interface())
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
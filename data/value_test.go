@@ -0,0 +1,45 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValueJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		value Value
+		field string
+	}{
+		{value: NewNumber(0), field: `"Number":0`},
+		{value: NewNumber(36.6), field: `"Number":36.6`},
+		{value: NewBoolean(false), field: `"Boolean":false`},
+		{value: NewBoolean(true), field: `"Boolean":true`},
+		{value: NewText("")},
+		{value: NewArray([]Value{NewNumber(0), NewBoolean(false)})},
+	}
+
+	for _, test := range tests {
+		bytes, err := json.Marshal(test.value)
+
+		if err != nil {
+			t.Fatalf("Marshal(%+v) failed: %s", test.value, err)
+		}
+
+		if !strings.Contains(string(bytes), test.field) {
+			t.Errorf("Marshal(%+v) = %s, want %s", test.value, bytes, test.field)
+		}
+
+		var decoded Value
+		err = json.Unmarshal(bytes, &decoded)
+
+		if err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %s", bytes, err)
+		}
+
+		if !reflect.DeepEqual(decoded, test.value) {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", bytes, decoded, test.value)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"biocad-opcua/shared"
+	"log"
+	"time"
+)
+
+// healthCheckInterval is the interval of checking if the sources receive the values of the parameters.
+const healthCheckInterval = 10 * time.Second
+
+// watchHealth checks the health of the sources at the interval
+// and logs when any of them stops or starts receiving the values.
+func watchHealth(sources []shared.Source, interval time.Duration, logger *log.Logger) {
+	// The sources are considered healthy until the first check.
+	unhealthy := make(map[string]bool, len(sources))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, source := range sources {
+			err := source.Health()
+
+			switch {
+			case err != nil && !unhealthy[source.Name()]:
+				logger.Printf("The source '%s' is unhealthy: %s", source.Name(), err)
+
+			case err == nil && unhealthy[source.Name()]:
+				logger.Printf("The source '%s' is healthy again", source.Name())
+			}
+
+			unhealthy[source.Name()] = err != nil
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	_ "github.com/influxdata/influxdb1-client"
@@ -26,28 +27,112 @@ const (
 )
 
 var (
+	serversPath    string
+	serverName     string
 	endpoint       string
+	backups        string
+	minLevel       int
+	mode           string
+	securityPolicy string
+	securityMode   string
+	certFile       string
+	keyFile        string
+	trustedCerts   string
+	applicationURI string
+	generateCert   bool
+	authMode       string
+	usernameFile   string
+	passwordFile   string
+	userCertFile   string
 	parametersPath string
+	browseRoot     string
+	browsePattern  string
+	browseNs       int
+	browseTypes    string
+	eventNotifier  string
+	methodsPath    string
+	historyAccess  bool
 	dbAddress      string
 	database       string
 	cacheAddress   string
 	brokerAddress  string
 	topic          string
+	commandsTopic  string
 	capacity       int
 	launchTimeout  int
+	publishing     int
+	reloadInterval int
+	listEndpoints  bool
+	recordDir      string
+	replayPath     string
+	replaySpeed    float64
 )
 
+// recorders write the data received by the sources. They're closed on the fatal errors too,
+// since the deferred calls aren't run then.
+var recorders []io.Closer
+
 func parseFlags() {
+	flag.StringVar(&serversPath, "servers", "",
+		"JSON file describing the servers to monitor (the single server flags are used if empty)")
+	flag.StringVar(&serverName, "server-name", "default", "Name of the server the measurements are tagged with")
 	flag.StringVar(&endpoint, "endpoint", "opc.tcp://localhost:53530/OPCUA/SimulationServer",
 		"Address of the OPC UA server")
-	flag.StringVar(&parametersPath, "params", "", "File containing OPC UA NodeIDs of the parameters")
+	flag.StringVar(&backups, "backup-endpoints", "",
+		"Comma-separated addresses of the redundant OPC UA servers to fail over to in order of preference")
+	flag.IntVar(&minLevel, "min-service-level", 200,
+		"ServiceLevel below which the server is considered degraded and the monitor fails over to a healthy backup")
+	flag.StringVar(&mode, "mode", monitoring.ModeSubscription,
+		"Acquisition mode: subscription or polling (reading the parameters at the publishing interval)")
+	flag.StringVar(&securityPolicy, "security-policy", "None",
+		"Security policy: None, Basic128Rsa15, Basic256, Basic256Sha256 or Auto (the most secure one)")
+	flag.StringVar(&securityMode, "security-mode", "None",
+		"Security mode: None, Sign, SignAndEncrypt or Auto (the most secure one)")
+	flag.StringVar(&certFile, "cert", "", "Application instance certificate file")
+	flag.StringVar(&keyFile, "key", "", "Private key file of the application instance certificate")
+	flag.StringVar(&trustedCerts, "trusted-certs", "", "Directory containing trusted server certificates")
+	flag.StringVar(&applicationURI, "application-uri", "urn:biocad-opcua:monitor",
+		"Application URI for the generated certificate")
+	flag.BoolVar(&generateCert, "generate-cert", false,
+		"Generate a self-signed certificate if the certificate file doesn't exist")
+	flag.StringVar(&authMode, "auth-mode", "Anonymous", "User identity token type: Anonymous, UserName or Certificate")
+	flag.StringVar(&usernameFile, "username-file", "",
+		"File containing the user name ("+monitoring.UsernameEnv+" is used if empty)")
+	flag.StringVar(&passwordFile, "password-file", "",
+		"File containing the user password ("+monitoring.PasswordEnv+" is used if empty)")
+	flag.StringVar(&userCertFile, "user-cert", "", "User certificate file for the Certificate identity token")
+	flag.StringVar(&parametersPath, "params", "",
+		"File containing OPC UA NodeIDs of the parameters or the JSON parameter definitions (*.json)")
+	flag.StringVar(&browseRoot, "browse-root", "",
+		"NodeID of the node to browse the parameters from (the parameters file is used if empty)")
+	flag.StringVar(&browsePattern, "browse-pattern", "", "Regular expression the parameter BrowseName must match")
+	flag.IntVar(&browseNs, "browse-namespace", -1, "Namespace index of the parameters to browse (-1 for any)")
+	flag.StringVar(&browseTypes, "browse-types", "",
+		"Comma-separated list of the parameter data types to browse (Double, Int32, etc.)")
+	flag.StringVar(&eventNotifier, "event-notifier", "",
+		"NodeID of the node to receive the alarms and events from (i=2253 for the Server object, disabled if empty)")
+	flag.StringVar(&methodsPath, "methods", "", "JSON file listing the methods allowed to be called on the server")
+	flag.BoolVar(&historyAccess, "history-access", false,
+		"Fill the gaps in the stored data from the history of the server after the server or the database outages")
 	flag.StringVar(&dbAddress, "dbaddress", "http://localhost:8086",
 		"Addres of the database server")
 	flag.StringVar(&database, "database", "system_indicators", "Name of the database to store data")
 	flag.StringVar(&cacheAddress, "cacheaddress", "", "Address and port of the cache server")
 	flag.StringVar(&brokerAddress, "brokerhost", "", "Address of the message broker")
 	flag.StringVar(&topic, "topic", "measures", "Name of the topic to spread measures across the system")
+	flag.StringVar(&commandsTopic, "commands", "commands", "Name of the topic to receive commands from the other services")
 	flag.IntVar(&capacity, "capacity", 60, "Number of points per measurment series")
+	flag.IntVar(&publishing, "publishing-interval", 1000, "Publishing interval of the subscription in milliseconds")
+	flag.IntVar(&reloadInterval, "reload-interval", 10,
+		"Interval in seconds of checking the parameter files for changes (0 to reload on SIGHUP only)")
+	flag.BoolVar(&listEndpoints, "list-endpoints", false,
+		"Print the endpoints offered by the servers and exit (* marks the endpoint the monitor would select)")
+	flag.StringVar(&recordDir, "record", "",
+		"Directory to record the raw notifications of the servers to (recording is disabled if empty)")
+	flag.StringVar(&replayPath, "replay", "",
+		"Recording to replay to the database and the other services instead of monitoring the servers")
+	flag.Float64Var(&replaySpeed, "replay-speed", 1,
+		"Speed of the replay relative to the recording (0 to replay without waiting)")
 	flag.IntVar(&launchTimeout, "launch-timeout", 5, "Time to sleep before starting the application")
 
 	flag.Parse()
@@ -56,6 +141,16 @@ func parseFlags() {
 func main() {
 	parseFlags()
 
+	if listEndpoints {
+		err := printEndpoints()
+
+		if err != nil {
+			log.Fatalln("Couldn't list the endpoints:", err)
+		}
+
+		return
+	}
+
 	// Sleep to give other microservices time to start up.
 	time.Sleep(time.Duration(launchTimeout) * time.Second)
 
@@ -83,19 +178,53 @@ func main() {
 	stream := io.MultiWriter(os.Stdout, file)
 	logger := log.New(stream, PREFIX, log.LstdFlags|log.Lshortfile)
 
-	// Create a monitor.
 	ctx := context.Background()
-	interval := 1 * time.Second
-	monitor := monitoring.NewOpcuaMonitor(ctx, endpoint, logger, interval)
-	err = monitor.Connect()
-	defer monitor.CloseConnection()
-	handleError(logger, "Couldn't connect to the server", err)
+
+	// Feed the recorded notifications to the services instead of the servers' ones.
+	if replayPath != "" {
+		err = replayRecording(ctx, logger)
+		handleError(logger, "Couldn't replay the recording", err)
+
+		return
+	}
+
+	// Create a monitor for each server.
+	interval := time.Duration(publishing) * time.Millisecond
+	configs, err := loadConnectionConfigs()
+	handleError(logger, "Couldn't load the servers configuration", err)
+
+	sources := make([]shared.Source, 0, len(configs))
+
+	for _, config := range configs {
+		var source shared.Source = monitoring.NewOpcuaMonitor(ctx, config, logger, interval)
+
+		// The source keeps trying to connect if the server is unavailable.
+		err = source.Connect()
+
+		if err != nil {
+			logger.Printf("Couldn't connect to the server '%s': %s", config.Name, err)
+		}
+
+		defer source.CloseConnection()
+		sources = append(sources, source)
+	}
 
 	// Create a cache client to store the alerting thresholds for the parameters.
 	cache := shared.NewCache(cacheAddress, logger)
 	cache.Connect()
 	defer cache.CloseConnection()
 
+	// The parameters cached before several servers could be monitored belong to the only
+	// server monitored then, which is the first one of the configuration.
+	if len(configs) > 0 {
+		moved, err := cache.MigrateLegacyKeys(configs[0].Name)
+		handleError(logger, "Couldn't migrate the legacy keys of the cache", err)
+
+		if moved > 0 {
+			logger.Printf("Moved %d cached parameters to the server '%s'", moved, configs[0].Name)
+		}
+	}
+
 	// Create a database client and connect to the database.
 	dbclient := shared.NewDbClient(dbAddress, database, logger, capacity)
 	err = dbclient.Connect()
@@ -108,44 +237,42 @@ func main() {
 	handleError(logger, "Couldn't connect to the message broker", err)
 	defer pb.CloseConnection()
 
-	// Load parameters (NodeIDs) from the file and monitor them.
-	parameters, err := monitoring.LoadParametersFromFile(parametersPath)
-	handleError(logger, "Couldn't open file with parameters", err)
+	// Monitor the parameters of all the servers.
+	for _, source := range sources {
+		// Discover the parameters on the server or load them from the file.
+		parameters, err := source.LoadParameters()
+		handleError(logger, "Couldn't obtain the parameters to monitor", err)
 
-	// Monitor all the parameters from the file.
-	for _, parameter := range parameters {
-		// Get the parameter name.
-		tokens := strings.Split(parameter, ";")
-		tokens = strings.Split(tokens[1], "=")
-		name := tokens[1]
+		// The invalid parameters are skipped, and the rejected ones are retried by the source.
+		for _, result := range source.MonitorParameters(parameters) {
+			parameter := result.Parameter
 
-		// Check if the parameter exists in the cache.
-		exists, err := cache.CheckParameterExists(name)
-		handleError(logger, "Couldn't check the parameter for existence", err)
+			if result.Err != nil {
+				logger.Printf("Couldn't monitor the parameter '%s' of the server '%s' (retrying: %t): %s",
+					parameter.Name, source.Name(), result.Retrying, result.Err)
+			}
 
-		// If the parameter doesn't exist in the cache, set its bounds to default.
-		if !exists {
-			err = cache.AddParameters(name)
-			handleError(logger, "Couldn't add the parameter name in the cache", err)
+			if result.Err != nil && !result.Retrying {
+				continue
+			}
+
+			err = addParameterToCache(cache, source.Name(), parameter)
+			handleError(logger, "Couldn't add the parameter to the cache", err)
 		}
 
-		err = monitor.MonitorParameter(parameter)
-		handleError(logger, "Couldn't send parameter to monitoring", err)
+		// Receive the alarms of the devices.
+		if events, ok := source.(shared.EventSource); ok {
+			err = events.MonitorEvents()
+			handleError(logger, "Couldn't send the events to monitoring", err)
+		}
 	}
 
 	// Console subscriber.
-	go func() {
-		channel := make(chan data.Measurement)
-		monitor.AddSubscriber(channel)
-
-		for measure := range channel {
-			params, ok := measure.(data.ParametersState)
+	console := make(chan data.Measurement)
 
-			if !ok {
-				handleError(logger, "Couldn't assert the type", fmt.Errorf("invalid type cast"))
-			}
-
-			bytes, err := json.MarshalIndent(params, "", "    ")
+	go func() {
+		for measure := range console {
+			bytes, err := json.MarshalIndent(measure, "", "    ")
 			handleError(logger, "Couldn't marshal the object to JSON", err)
 
 			fmt.Println("alpha", string(bytes))
@@ -153,33 +280,261 @@ func main() {
 	}()
 
 	// Database subscriber.
-	channel := dbclient.GetSubscriptionChannel()
-
-	monitor.AddSubscriber(channel)
+	dbchannel := dbclient.GetSubscriptionChannel()
 	dbclient.Start()
 	defer dbclient.Stop()
 
-	// Publisher.
-	channel = pb.GetChannel()
+	// Restore the data lost while the database was unavailable from the history of the servers.
+	go func() {
+		for outage := range dbclient.Outages() {
+			for _, source := range sources {
+				if backfiller, ok := source.(shared.Backfiller); ok {
+					backfiller.BackfillPeriod(outage.Since, outage.Until)
+				}
+			}
+		}
+	}()
 
-	monitor.AddSubscriber(channel)
+	// Publisher.
+	pbchannel := pb.GetChannel()
 	pb.Start()
 	defer pb.Stop()
 
-	// Start the monitor.
-	monitor.Start()
-	defer monitor.Stop()
+	// Responder executing the commands of the other services.
+	responder := shared.NewResponder(brokerAddress, commandsTopic, logger)
+	err = responder.Connect()
+	handleError(logger, "Couldn't connect to the message broker", err)
+	defer responder.CloseConnection()
+
+	err = responder.HandleWrite(func(command data.WriteCommand) data.CommandResult {
+		return writeParameter(sources, command)
+	})
+	handleError(logger, "Couldn't receive the write commands", err)
+
+	err = responder.HandleCall(func(command data.CallCommand) data.CommandResult {
+		return callMethod(sources, command)
+	})
+	handleError(logger, "Couldn't receive the method calls", err)
+
+	// Record the notifications of the servers to replay them later.
+	if recordDir != "" {
+		recorders, err = startRecording(sources, recordDir, logger)
+		handleError(logger, "Couldn't start recording", err)
+		defer closeRecorders(recorders, logger)
+	}
+
+	// Start the sources.
+	for _, source := range sources {
+		source.AddSubscriber(console)
+		source.AddSubscriber(dbchannel)
+		source.AddSubscriber(pbchannel)
+
+		// Some of the protocols provide the history, the metadata and the diagnostics.
+		if backfiller, ok := source.(shared.Backfiller); ok {
+			backfiller.EnableBackfill(dbclient, dbchannel)
+		}
+
+		if describer, ok := source.(shared.Describer); ok {
+			describer.EnableMetadata(cache)
+		}
+
+		if diagnosable, ok := source.(shared.Diagnosable); ok {
+			diagnosable.EnableDiagnostics(cache)
+		}
+
+		source.Start()
+		defer source.Stop()
+	}
+
+	// Reload the parameters on SIGHUP or when the parameter files change.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	changes := make(chan interface{})
+
+	// The sources discovering the parameters on the devices ask for reloading them.
+	for _, source := range sources {
+		if notifier, ok := source.(shared.ReloadNotifier); ok {
+			notifier.NotifyReload(changes)
+		}
+	}
+
+	if reloadInterval > 0 {
+		go watchParameterFiles(configs, time.Duration(reloadInterval)*time.Second, changes)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-hangup:
+				logger.Println("Received SIGHUP, reloading the parameters")
+			case <-changes:
+				logger.Println("The parameter sources have changed, reloading the parameters")
+			}
+
+			reloadParameters(sources, cache, logger)
+		}
+	}()
+
+	// Log the sources which stop receiving the values of the parameters.
+	go watchHealth(sources, healthCheckInterval, logger)
 
 	// Interrupt.
-	interrupt := make(chan os.Signal)
+	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Kill, os.Interrupt)
 
 	<-interrupt
 	logger.Println("Alerter stopped.")
 }
 
+// loadConnectionConfigs reads the servers file or builds
+// the configuration of a single server from the flags.
+func loadConnectionConfigs() ([]monitoring.ConnectionConfig, error) {
+	if serversPath != "" {
+		return monitoring.LoadConnectionConfigs(serversPath)
+	}
+
+	config := monitoring.ConnectionConfig{
+		Name:            serverName,
+		Endpoint:        endpoint,
+		MinServiceLevel: minLevel,
+		Mode:            mode,
+		Security: monitoring.SecurityConfig{
+			Policy:              securityPolicy,
+			Mode:                securityMode,
+			CertificateFile:     certFile,
+			PrivateKeyFile:      keyFile,
+			TrustedCertsDir:     trustedCerts,
+			ApplicationURI:      applicationURI,
+			GenerateCertificate: generateCert,
+		},
+		Identity: monitoring.IdentityConfig{
+			Type:            authMode,
+			UsernameFile:    usernameFile,
+			PasswordFile:    passwordFile,
+			CertificateFile: userCertFile,
+		},
+		Parameters: monitoring.ParameterSource{
+			File:            parametersPath,
+			BrowseRoot:      browseRoot,
+			BrowsePattern:   browsePattern,
+			BrowseNamespace: browseNs,
+		},
+		EventNotifier: eventNotifier,
+		HistoryAccess: historyAccess,
+	}
+
+	if backups != "" {
+		config.BackupEndpoints = strings.Split(backups, ",")
+	}
+
+	if browseTypes != "" {
+		config.Parameters.BrowseTypes = strings.Split(browseTypes, ",")
+	}
+
+	if methodsPath != "" {
+		methods, err := monitoring.LoadMethodsFromFile(methodsPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.Methods = methods
+	}
+
+	return []monitoring.ConnectionConfig{config}, config.Validate()
+}
+
+// printEndpoints prints the endpoints offered by each server to help choosing the security settings.
+func printEndpoints() error {
+	configs, err := loadConnectionConfigs()
+
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		for _, address := range config.Endpoints() {
+			endpoints, err := monitoring.DiscoverEndpoints(address)
+
+			if err != nil {
+				return fmt.Errorf("server '%s': %s", config.Name, err)
+			}
+
+			// The endpoint isn't marked if the settings don't match any of them.
+			selected, _ := config.SelectEndpoint(endpoints)
+			fmt.Printf("%s (%s):\n", config.Name, address)
+
+			for _, endpoint := range endpoints {
+				marker := " "
+
+				if endpoint == selected {
+					marker = "*"
+				}
+
+				fmt.Printf("%s %s\n", marker, monitoring.FormatEndpoint(endpoint))
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeParameter sends the write command to the source of the server the parameter belongs to.
+func writeParameter(sources []shared.Source, command data.WriteCommand) data.CommandResult {
+	for _, source := range sources {
+		if command.Server != "" && command.Server != source.Name() {
+			continue
+		}
+
+		if command.Server == "" && !source.HasParameter(command.Parameter) {
+			continue
+		}
+
+		if writer, ok := source.(shared.ParameterWriter); ok {
+			return writer.WriteParameter(command)
+		}
+
+		return data.CommandResult{
+			Error: fmt.Sprintf("The parameters of the server '%s' can't be written", source.Name()),
+		}
+	}
+
+	return data.CommandResult{
+		Error: fmt.Sprintf("The parameter '%s' is not monitored on the server '%s'", command.Parameter, command.Server),
+	}
+}
+
+// callMethod sends the method call to the source of the server allowing the method.
+func callMethod(sources []shared.Source, command data.CallCommand) data.CommandResult {
+	for _, source := range sources {
+		if command.Server != "" && command.Server != source.Name() {
+			continue
+		}
+
+		caller, ok := source.(shared.MethodCaller)
+
+		if command.Server == "" && (!ok || !caller.HasMethod(command.Method)) {
+			continue
+		}
+
+		if ok {
+			return caller.CallMethod(command)
+		}
+
+		return data.CommandResult{
+			Error: fmt.Sprintf("The methods of the server '%s' can't be called", source.Name()),
+		}
+	}
+
+	return data.CommandResult{
+		Error: fmt.Sprintf("The method '%s' is not allowed on the server '%s'", command.Method, command.Server),
+	}
+}
+
 func handleError(logger *log.Logger, message string, err error) {
 	if err != nil {
+		closeRecorders(recorders, logger)
 		logger.Fatalf("%s: %s", message, err)
 	}
 }
@@ -0,0 +1,338 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// maxBrowseDepth limits how deep the monitor descends
+	// into the address space from the root node.
+	maxBrowseDepth = 10
+	// maxReferencesPerNode is the number of references
+	// the server returns for a node in a single response.
+	maxReferencesPerNode = 1000
+	// maxNodesPerRead limits the number of the attributes read with a single request.
+	maxNodesPerRead = 1000
+	// browsePathSeparator joins the BrowseNames of the nodes
+	// on the path from the root node to the variable.
+	browsePathSeparator = "."
+)
+
+// BrowseFilter selects the variables to monitor among
+// the ones found in the address space of the server.
+type BrowseFilter struct {
+	// Pattern the BrowseName of the variable must match.
+	// Any name is accepted if the pattern is nil.
+	Pattern *regexp.Regexp
+	// Namespace index of the variable NodeID.
+	// Any namespace is accepted if the index is negative.
+	Namespace int
+	// DataTypes are the names of the built-in data types
+	// (Double, Int32, Boolean, etc.) the variable may have.
+	// Any data type is accepted if the list is empty.
+	DataTypes []string
+}
+
+// BrowseParameters walks the address space of the server starting
+// from the root node and returns all the variables accepted by the filter.
+// The parameter is named after the browse path of the variable relative to the root node,
+// e.g. 'Tank1.Temperature', since the BrowseNames are only unique among the siblings.
+// The NodeIDs are stored with the namespace URI, so they survive reordering the namespaces.
+func (monitor *OpcuaMonitor) BrowseParameters(root string, filter BrowseFilter) ([]Parameter, error) {
+	monitor.synchronizer.Lock()
+	namespaces := monitor.namespaces
+	monitor.synchronizer.Unlock()
+
+	rootID, err := resolveNodeID(root, namespaces)
+	monitor.handleBrowseError(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	variables := make([]*ua.ReferenceDescription, 0)
+	visited := map[string]bool{rootID.String(): true}
+	// paths are the browse paths of the nodes relative to the root node.
+	paths := map[string]string{rootID.String(): ""}
+	level := []*ua.NodeID{rootID}
+
+	// Walk the tree level by level collecting the variables.
+	for depth := 0; depth < maxBrowseDepth && len(level) > 0; depth++ {
+		refs, parents, err := monitor.browseChildren(level)
+		monitor.handleBrowseError(err)
+
+		if err != nil {
+			return nil, err
+		}
+
+		next := make([]*ua.NodeID, 0)
+
+		for i, ref := range refs {
+			nodeID := ref.NodeID.NodeID
+			key := nodeID.String()
+
+			if visited[key] || ref.BrowseName == nil {
+				continue
+			}
+
+			visited[key] = true
+			paths[key] = joinBrowsePath(paths[parents[i].String()], ref.BrowseName.Name)
+
+			switch ref.NodeClass {
+			case ua.NodeClassObject:
+				next = append(next, nodeID)
+
+			case ua.NodeClassVariable:
+				if filter.accepts(ref) {
+					variables = append(variables, ref)
+				}
+			}
+		}
+
+		level = next
+	}
+
+	// Filter the variables by data type.
+	if len(filter.DataTypes) > 0 {
+		variables, err = monitor.filterByDataType(variables, filter.DataTypes)
+		monitor.handleBrowseError(err)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parameters := make([]Parameter, 0, len(variables))
+	names := make(map[string]bool, len(variables))
+
+	for _, variable := range variables {
+		nodeID := variable.NodeID.NodeID
+		name := paths[nodeID.String()]
+
+		// The siblings may still have the same BrowseName in different namespaces.
+		if names[name] {
+			monitor.logger.Printf("Skipping the variable %s: the parameter '%s' already exists", nodeID, name)
+			continue
+		}
+
+		names[name] = true
+		parameters = append(parameters, Parameter{
+			Name:     name,
+			NodeID:   formatNodeID(nodeID, namespaces),
+			Sampling: DefaultSampling(),
+		})
+	}
+
+	monitor.logger.Printf("Found %d parameters under the node %s", len(parameters), root)
+
+	return parameters, nil
+}
+
+// browseChildren returns the hierarchical forward references of all the given nodes
+// along with the node each of the references belongs to.
+func (monitor *OpcuaMonitor) browseChildren(nodes []*ua.NodeID) ([]*ua.ReferenceDescription, []*ua.NodeID, error) {
+	descriptions := make([]*ua.BrowseDescription, len(nodes))
+
+	for i, node := range nodes {
+		descriptions[i] = &ua.BrowseDescription{
+			NodeID:          node,
+			BrowseDirection: ua.BrowseDirectionForward,
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HierarchicalReferences),
+			IncludeSubtypes: true,
+			NodeClassMask:   uint32(ua.NodeClassObject | ua.NodeClassVariable),
+			ResultMask:      uint32(ua.BrowseResultMaskAll),
+		}
+	}
+
+	res, err := monitor.connection.Browse(&ua.BrowseRequest{
+		View: &ua.ViewDescription{
+			ViewID:    ua.NewTwoByteNodeID(0),
+			Timestamp: time.Now(),
+		},
+		RequestedMaxReferencesPerNode: maxReferencesPerNode,
+		NodesToBrowse:                 descriptions,
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refs := make([]*ua.ReferenceDescription, 0)
+	parents := make([]*ua.NodeID, 0)
+	results := res.Results
+	// owners are the nodes the results belong to.
+	owners := nodes
+
+	for len(results) > 0 {
+		if len(results) != len(owners) {
+			return nil, nil, fmt.Errorf("The server returned %d results for %d nodes", len(results), len(owners))
+		}
+
+		continuationPoints := make([][]byte, 0)
+		continued := make([]*ua.NodeID, 0)
+
+		for i, result := range results {
+			if result.StatusCode != ua.StatusOK {
+				monitor.logger.Println("Couldn't browse the node:", result.StatusCode)
+				continue
+			}
+
+			refs = append(refs, result.References...)
+
+			for range result.References {
+				parents = append(parents, owners[i])
+			}
+
+			if len(result.ContinuationPoint) > 0 {
+				continuationPoints = append(continuationPoints, result.ContinuationPoint)
+				continued = append(continued, owners[i])
+			}
+		}
+
+		if len(continuationPoints) == 0 {
+			break
+		}
+
+		// Get the rest of the references the server couldn't return at once.
+		results, err = monitor.browseNext(continuationPoints)
+		owners = continued
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return refs, parents, nil
+}
+
+// browseNext requests the references which are left for the continuation points.
+func (monitor *OpcuaMonitor) browseNext(continuationPoints [][]byte) ([]*ua.BrowseResult, error) {
+	var res *ua.BrowseNextResponse
+
+	err := monitor.connection.Send(&ua.BrowseNextRequest{
+		ReleaseContinuationPoints: false,
+		ContinuationPoints:        continuationPoints,
+	}, func(v interface{}) error {
+		response, ok := v.(*ua.BrowseNextResponse)
+
+		if !ok {
+			return fmt.Errorf("invalid response type %T", v)
+		}
+
+		res = response
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Results, nil
+}
+
+// filterByDataType reads the DataType attribute of the variables
+// and leaves only the ones having one of the specified data types.
+func (monitor *OpcuaMonitor) filterByDataType(variables []*ua.ReferenceDescription, dataTypes []string) ([]*ua.ReferenceDescription, error) {
+	filtered := make([]*ua.ReferenceDescription, 0)
+
+	for start := 0; start < len(variables); start += maxNodesPerRead {
+		end := start + maxNodesPerRead
+
+		if end > len(variables) {
+			end = len(variables)
+		}
+
+		chunk := variables[start:end]
+		nodesToRead := make([]*ua.ReadValueID, len(chunk))
+
+		for i, variable := range chunk {
+			nodesToRead[i] = &ua.ReadValueID{
+				NodeID:      variable.NodeID.NodeID,
+				AttributeID: ua.AttributeIDDataType,
+			}
+		}
+
+		res, err := monitor.connection.Read(&ua.ReadRequest{
+			NodesToRead: nodesToRead,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res.Results) != len(chunk) {
+			return nil, fmt.Errorf("The server returned %d results for %d variables", len(res.Results), len(chunk))
+		}
+
+		for i, result := range res.Results {
+			if result.Status != ua.StatusOK || result.Value == nil {
+				continue
+			}
+
+			dataType, ok := result.Value.Value().(*ua.NodeID)
+
+			if !ok {
+				continue
+			}
+
+			for _, name := range dataTypes {
+				if strings.EqualFold(dataTypeName(dataType), name) {
+					filtered = append(filtered, chunk[i])
+					break
+				}
+			}
+		}
+	}
+
+	return filtered, nil
+}
+
+// joinBrowsePath appends the BrowseName of the node to the browse path of its parent.
+func joinBrowsePath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + browsePathSeparator + name
+}
+
+// accepts checks if the variable NodeID and BrowseName pass the filter.
+func (filter BrowseFilter) accepts(ref *ua.ReferenceDescription) bool {
+	if ref.BrowseName == nil {
+		return false
+	}
+
+	if filter.Namespace >= 0 && int(ref.NodeID.NodeID.Namespace()) != filter.Namespace {
+		return false
+	}
+
+	if filter.Pattern != nil && !filter.Pattern.MatchString(ref.BrowseName.Name) {
+		return false
+	}
+
+	return true
+}
+
+// dataTypeName returns the name of the built-in data type
+// with the given NodeID or its string form for other data types.
+func dataTypeName(dataType *ua.NodeID) string {
+	if dataType.Namespace() == 0 && dataType.IntID() >= uint32(ua.TypeIDBoolean) &&
+		dataType.IntID() <= uint32(ua.TypeIDDiagnosticInfo) {
+		return strings.TrimPrefix(ua.TypeID(dataType.IntID()).String(), "TypeID")
+	}
+
+	return dataType.String()
+}
+
+func (monitor *OpcuaMonitor) handleBrowseError(err error) {
+	if err != nil {
+		monitor.logger.Println("Couldn't browse the address space of the server:", err)
+	}
+}
@@ -0,0 +1,132 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// variantFromValue converts the parameter value to the variant of the node data type.
+func variantFromValue(value data.Value, dataType *ua.NodeID) (*ua.Variant, error) {
+	raw, err := coerceValue(value, dataType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ua.NewVariant(raw)
+}
+
+// coerceValue converts the parameter value to the Go value
+// corresponding to the built-in OPC UA data type.
+func coerceValue(value data.Value, dataType *ua.NodeID) (interface{}, error) {
+	if dataType == nil || dataType.Namespace() != 0 {
+		return nil, fmt.Errorf("Unsupported data type %v", dataType)
+	}
+
+	switch dataType.IntID() {
+	case id.Boolean:
+		switch value.Kind {
+		case data.KindBoolean:
+			return value.Boolean, nil
+
+		case data.KindNumber:
+			return value.Number != 0, nil
+		}
+
+	case id.SByte:
+		number, err := integerValue(value, math.MinInt8, math.MaxInt8+1)
+		return int8(number), err
+
+	case id.Byte:
+		number, err := integerValue(value, 0, math.MaxUint8+1)
+		return uint8(number), err
+
+	case id.Int16:
+		number, err := integerValue(value, math.MinInt16, math.MaxInt16+1)
+		return int16(number), err
+
+	case id.UInt16:
+		number, err := integerValue(value, 0, math.MaxUint16+1)
+		return uint16(number), err
+
+	case id.Int32:
+		number, err := integerValue(value, math.MinInt32, math.MaxInt32+1)
+		return int32(number), err
+
+	case id.UInt32:
+		number, err := integerValue(value, 0, math.MaxUint32+1)
+		return uint32(number), err
+
+	case id.Int64:
+		number, err := integerValue(value, math.MinInt64, math.MaxInt64+1)
+		return int64(number), err
+
+	case id.UInt64:
+		number, err := integerValue(value, 0, math.MaxUint64+1)
+		return uint64(number), err
+
+	case id.Float:
+		if number, ok := value.Float(); ok {
+			return float32(number), nil
+		}
+
+	case id.Double:
+		if number, ok := value.Float(); ok {
+			return number, nil
+		}
+
+	case id.String:
+		switch value.Kind {
+		case data.KindText:
+			return value.Text, nil
+
+		case data.KindNumber:
+			return strconv.FormatFloat(value.Number, 'g', -1, 64), nil
+
+		case data.KindBoolean:
+			return strconv.FormatBool(value.Boolean), nil
+		}
+
+	case id.DateTime:
+		if value.Kind == data.KindText {
+			return time.Parse(time.RFC3339Nano, value.Text)
+		}
+
+	case id.LocalizedText:
+		if value.Kind == data.KindText {
+			return &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: value.Text}, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported data type %s", dataType)
+	}
+
+	return nil, fmt.Errorf("Couldn't convert the %s value to the data type %s", value.Kind, dataType)
+}
+
+// integerValue returns the numeric value if it's an integer within the range [min, limit).
+// The upper bound is exclusive since the maximum 64-bit integers can't be represented
+// as float64: they're rounded up to the limit which overflows the integer type.
+func integerValue(value data.Value, min, limit float64) (float64, error) {
+	number, ok := value.Float()
+
+	if !ok {
+		return 0, fmt.Errorf("The %s value is not a number", value.Kind)
+	}
+
+	if number != math.Trunc(number) {
+		return 0, fmt.Errorf("The value %v is not an integer", number)
+	}
+
+	if number < min || number >= limit {
+		return 0, fmt.Errorf("The value %v is out of the data type range", number)
+	}
+
+	return number, nil
+}
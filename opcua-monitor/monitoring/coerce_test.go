@@ -0,0 +1,95 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		value    data.Value
+		dataType uint32
+		want     interface{}
+		fails    bool
+	}{
+		{value: data.NewBoolean(true), dataType: id.Boolean, want: true},
+		{value: data.NewNumber(0), dataType: id.Boolean, want: false},
+		{value: data.NewText("true"), dataType: id.Boolean, fails: true},
+		{value: data.NewNumber(-128), dataType: id.SByte, want: int8(-128)},
+		{value: data.NewNumber(127), dataType: id.SByte, want: int8(127)},
+		{value: data.NewNumber(128), dataType: id.SByte, fails: true},
+		{value: data.NewNumber(255), dataType: id.Byte, want: uint8(255)},
+		{value: data.NewNumber(-1), dataType: id.Byte, fails: true},
+		{value: data.NewNumber(32767), dataType: id.Int16, want: int16(32767)},
+		{value: data.NewNumber(65536), dataType: id.UInt16, fails: true},
+		{value: data.NewNumber(-2147483648), dataType: id.Int32, want: int32(-2147483648)},
+		{value: data.NewNumber(4294967295), dataType: id.UInt32, want: uint32(4294967295)},
+		{value: data.NewNumber(1.5), dataType: id.Int32, fails: true},
+		{value: data.NewBoolean(true), dataType: id.Int32, fails: true},
+		{value: data.NewNumber(math.MinInt64), dataType: id.Int64, want: int64(math.MinInt64)},
+		// The maximum 64-bit integers are rounded up to 2^63 and 2^64 as float64.
+		{value: data.NewNumber(math.MaxInt64), dataType: id.Int64, fails: true},
+		{value: data.NewNumber(math.MaxUint64), dataType: id.UInt64, fails: true},
+		{value: data.NewNumber(1 << 62), dataType: id.Int64, want: int64(1 << 62)},
+		{value: data.NewNumber(1 << 63), dataType: id.UInt64, want: uint64(1 << 63)},
+		{value: data.NewNumber(0.5), dataType: id.Float, want: float32(0.5)},
+		{value: data.NewNumber(0.1), dataType: id.Double, want: 0.1},
+		{value: data.NewText("0.1"), dataType: id.Double, fails: true},
+		{value: data.NewText("on"), dataType: id.String, want: "on"},
+		{value: data.NewNumber(2.5), dataType: id.String, want: "2.5"},
+		{value: data.NewBoolean(false), dataType: id.String, want: "false"},
+		{
+			value:    data.NewText("2020-01-02T03:04:05Z"),
+			dataType: id.DateTime,
+			want:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{value: data.NewText("yesterday"), dataType: id.DateTime, fails: true},
+		{
+			value:    data.NewText("Idle"),
+			dataType: id.LocalizedText,
+			want:     &ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: "Idle"},
+		},
+		{value: data.NewNumber(1), dataType: id.GUID, fails: true},
+	}
+
+	for _, test := range tests {
+		got, err := coerceValue(test.value, ua.NewNumericNodeID(0, test.dataType))
+
+		if test.fails {
+			if err == nil {
+				t.Errorf("coerceValue(%+v, %d) = %v, want an error", test.value, test.dataType, got)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("coerceValue(%+v, %d) failed: %s", test.value, test.dataType, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("coerceValue(%+v, %d) = %#v, want %#v", test.value, test.dataType, got, test.want)
+		}
+	}
+}
+
+func TestCoerceValueDataTypeNamespace(t *testing.T) {
+	_, err := coerceValue(data.NewNumber(1), ua.NewNumericNodeID(2, id.Double))
+
+	if err == nil {
+		t.Error("coerceValue accepted the data type of the namespace 2")
+	}
+
+	_, err = coerceValue(data.NewNumber(1), nil)
+
+	if err == nil {
+		t.Error("coerceValue accepted the missing data type")
+	}
+}
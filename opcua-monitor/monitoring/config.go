@@ -0,0 +1,252 @@
+package monitoring
+
+import (
+	"biocad-opcua/shared"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ParameterSource describes where the monitor obtains the parameters of the server.
+type ParameterSource struct {
+	// File is the parameters file used if browsing is disabled or fails:
+	// either the JSON definition file or the text file listing the NodeIDs.
+	File string
+	// BrowseRoot is the NodeID of the node to browse the parameters from.
+	// Browsing is disabled if it's empty.
+	BrowseRoot string
+	// BrowsePattern is the regular expression the BrowseName must match.
+	BrowsePattern string
+	// BrowseNamespace is the namespace index of the parameters (-1 for any).
+	BrowseNamespace int
+	// BrowseTypes are the data types of the parameters (any if empty).
+	BrowseTypes []string
+}
+
+// DefaultConnectionConfig returns the connection settings
+// used for the values missing in the servers file.
+func DefaultConnectionConfig() ConnectionConfig {
+	return ConnectionConfig{
+		Mode:            ModeSubscription,
+		MinServiceLevel: defaultMinServiceLevel,
+		Security: SecurityConfig{
+			Policy: "None",
+			Mode:   "None",
+		},
+		Identity: IdentityConfig{
+			Type: "Anonymous",
+		},
+		Parameters: ParameterSource{
+			BrowseNamespace: -1,
+		},
+	}
+}
+
+// LoadConnectionConfigs reads the list of the servers to monitor from the JSON file, e.g.:
+//
+//	[
+//		{
+//			"name": "skid-1",
+//			"endpoint": "opc.tcp://skid-1:4840",
+//			"backupEndpoints": ["opc.tcp://skid-1-backup:4840"],
+//			"security": {"policy": "Basic256Sha256", "mode": "SignAndEncrypt"},
+//			"parameters": {"file": "skid-1.txt"}
+//		}
+//	]
+func LoadConnectionConfigs(filePath string) ([]ConnectionConfig, error) {
+	bytes, err := ioutil.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	err = json.Unmarshal(bytes, &raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	configs := make([]ConnectionConfig, 0, len(raw))
+	names := make(map[string]bool)
+
+	for i, item := range raw {
+		config := DefaultConnectionConfig()
+		err = json.Unmarshal(item, &config)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: server #%d: %s", filePath, i+1, err)
+		}
+
+		if config.Name == "" {
+			return nil, fmt.Errorf("%s: server #%d: the name is missing", filePath, i+1)
+		}
+
+		err = config.Validate()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: server '%s': %s", filePath, config.Name, err)
+		}
+
+		if names[config.Name] {
+			return nil, fmt.Errorf("%s: server '%s' is defined twice", filePath, config.Name)
+		}
+
+		names[config.Name] = true
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+// Validate checks the connection settings which can't be checked by the server.
+func (config ConnectionConfig) Validate() error {
+	if config.Endpoint == "" {
+		return fmt.Errorf("the endpoint is missing")
+	}
+
+	for _, backup := range config.BackupEndpoints {
+		if backup == "" {
+			return fmt.Errorf("the backup endpoint is empty")
+		}
+	}
+
+	if config.MinServiceLevel < 0 || config.MinServiceLevel > 255 {
+		return fmt.Errorf("the minimum service level %d is out of range [0, 255]", config.MinServiceLevel)
+	}
+
+	if config.Mode != "" && config.Mode != ModeSubscription && config.Mode != ModePolling {
+		return fmt.Errorf("unknown mode '%s'", config.Mode)
+	}
+
+	return nil
+}
+
+// LoadParameters browses the address space of the server for the parameters
+// and falls back to the parameters file if browsing is disabled or fails.
+// If the server is unavailable, the monitor starts with the parameters file
+// (or without the parameters if there's none) and browses them as soon as it connects.
+// Reloading fails while the server is unavailable, so the loaded parameters are kept.
+// The monitor keeps the OPC UA settings of the loaded parameters, so they're
+// applied when the parameters are monitored.
+func (monitor *OpcuaMonitor) LoadParameters() ([]shared.Parameter, error) {
+	parameters, err := monitor.loadParameters()
+
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	results := make([]shared.Parameter, 0, len(parameters))
+
+	for _, parameter := range parameters {
+		monitor.loaded[parameter.Name] = parameter
+		results = append(results, parameter.neutral())
+	}
+
+	return results, nil
+}
+
+// loadParameters obtains the parameters from the parameter source.
+func (monitor *OpcuaMonitor) loadParameters() ([]Parameter, error) {
+	source := monitor.config.Parameters
+
+	// The parameters are also reloaded while the monitor is running.
+	monitor.synchronizer.Lock()
+	connected := monitor.connected
+	monitor.synchronizer.Unlock()
+
+	if source.BrowseRoot != "" && !connected && source.File == "" {
+		monitor.logger.Printf("The server '%s' is unavailable, browsing the parameters after connecting to it",
+			monitor.config.Name)
+
+		monitor.synchronizer.Lock()
+		monitor.browsePending = true
+		monitor.synchronizer.Unlock()
+
+		return []Parameter{}, nil
+	}
+
+	// The parameters file only stands in for the browsed parameters until the server is browsed,
+	// so the parameters already loaded aren't replaced with it while the server is unavailable.
+	if source.BrowseRoot != "" && !connected {
+		monitor.synchronizer.Lock()
+		monitor.browsePending = true
+		loaded := len(monitor.loaded)
+		monitor.synchronizer.Unlock()
+
+		if loaded > 0 {
+			return nil, fmt.Errorf("The server '%s' is unavailable to browse the parameters", monitor.config.Name)
+		}
+
+		monitor.logger.Printf("The server '%s' is unavailable, loading the parameters file until browsing them",
+			monitor.config.Name)
+	}
+
+	if source.BrowseRoot != "" && connected {
+		filter := BrowseFilter{
+			Namespace: source.BrowseNamespace,
+			DataTypes: source.BrowseTypes,
+		}
+
+		if source.BrowsePattern != "" {
+			pattern, err := regexp.Compile(source.BrowsePattern)
+
+			if err != nil {
+				return nil, err
+			}
+
+			filter.Pattern = pattern
+		}
+
+		parameters, err := monitor.BrowseParameters(source.BrowseRoot, filter)
+
+		if err == nil && len(parameters) > 0 {
+			monitor.synchronizer.Lock()
+			monitor.browsePending = false
+			monitor.synchronizer.Unlock()
+
+			return parameters, nil
+		}
+
+		monitor.logger.Println("Couldn't browse the parameters, falling back to the parameters file")
+	}
+
+	if strings.ToLower(filepath.Ext(source.File)) == ".json" {
+		return LoadParameterDefinitions(source.File)
+	}
+
+	return LoadParametersFromFile(source.File)
+}
+
+// NotifyReload makes the monitor notify the channel when its parameters have to be
+// reloaded, e.g. the browsing deferred until the server is available.
+func (monitor *OpcuaMonitor) NotifyReload(channel chan<- interface{}) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.reloads = channel
+}
+
+// requestDeferredBrowse asks for reloading the parameters
+// if browsing them has been deferred until connecting to the server.
+func (monitor *OpcuaMonitor) requestDeferredBrowse() {
+	monitor.synchronizer.Lock()
+	pending := monitor.browsePending
+	reloads := monitor.reloads
+	monitor.synchronizer.Unlock()
+
+	if !pending || reloads == nil {
+		return
+	}
+
+	// The reload loop may be busy reloading the parameters of another server.
+	go func() {
+		reloads <- true
+	}()
+}
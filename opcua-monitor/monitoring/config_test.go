@@ -0,0 +1,56 @@
+package monitoring
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+)
+
+func TestLoadParametersServerUnavailable(t *testing.T) {
+	filePath, remove := writeTempFile(t, "parameters.json", `[
+	{"nodeId": "ns=3;s=Temperature", "name": "Temperature"}
+]`)
+	defer remove()
+
+	config := DefaultConnectionConfig()
+	config.Name = "server"
+	config.Parameters = ParameterSource{File: filePath, BrowseRoot: "ns=3;s=Plant"}
+	monitor := NewOpcuaMonitor(context.Background(), config, log.New(ioutil.Discard, "", 0), 0)
+
+	// The monitor starts with the parameters file until the server is browsed.
+	parameters, err := monitor.LoadParameters()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parameters) != 1 || parameters[0].Name != "Temperature" {
+		t.Fatalf("LoadParameters() = %+v, want Temperature of the parameters file", parameters)
+	}
+
+	if !monitor.browsePending {
+		t.Error("Browsing the parameters isn't deferred until connecting to the server")
+	}
+
+	// The loaded parameters aren't replaced with the file while the server is unavailable.
+	err = ioutil.WriteFile(filePath, []byte(`[{"nodeId": "ns=3;s=Pressure", "name": "Pressure"}]`), 0666)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := monitor.ReloadParameters()
+
+	if err == nil {
+		t.Errorf("ReloadParameters() = %+v, want an error while the server is unavailable", changes)
+	}
+
+	if _, ok := monitor.loaded["Pressure"]; ok {
+		t.Error("The parameters file has replaced the loaded parameters")
+	}
+
+	if _, ok := monitor.loaded["Temperature"]; !ok {
+		t.Error("The loaded parameters have been dropped")
+	}
+}
@@ -0,0 +1,243 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// parameterDefinition is a parameter described in the definition file.
+type parameterDefinition struct {
+	NodeID      string
+	Name        string
+	Unit        string
+	Description string
+	DataType    string
+	Bounds      *data.Bounds
+	Sampling    *samplingDefinition
+	Writable    bool
+	Limits      *data.Bounds
+	MaxAge      string
+	Probe       bool
+}
+
+// samplingDefinition are the sampling settings described in the definition file.
+// The values have the same format as the options of the parameters text file.
+type samplingDefinition struct {
+	Interval      string
+	QueueSize     *uint32
+	DiscardOldest *bool
+	Deadband      string
+	Trigger       string
+}
+
+// LoadParameterDefinitions reads the parameters from the JSON definition file, e.g.:
+//
+//	[
+//		{
+//			"nodeId": "ns=3;s=Temperature",
+//			"name": "Temperature",
+//			"unit": "°C",
+//			"description": "Temperature of the culture medium",
+//			"dataType": "Double",
+//			"bounds": {"lowerBound": 30, "upperBound": 40},
+//			"sampling": {"interval": "100ms", "deadband": "absolute:0.1"},
+//			"maxAge": "30s"
+//		}
+//	]
+//
+// The errors are reported with the number of the line of the invalid parameter.
+func LoadParameterDefinitions(filePath string) ([]Parameter, error) {
+	content, err := ioutil.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	token, err := decoder.Token()
+
+	if err != nil {
+		return nil, definitionError(filePath, content, 0, err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("%s:1: the definitions must be a list of parameters", filePath)
+	}
+
+	parameters := make([]Parameter, 0)
+	names := make(map[string]bool)
+	offset := 0
+
+	for decoder.More() {
+		var raw json.RawMessage
+		err = decoder.Decode(&raw)
+
+		if err != nil {
+			return nil, definitionError(filePath, content, 0, err)
+		}
+
+		// The raw message is a copy of the input, so it's found right after the previous one.
+		offset += bytes.Index(content[offset:], raw)
+
+		parameter, err := parseDefinition(raw)
+
+		if err != nil {
+			return nil, definitionError(filePath, content, offset, err)
+		}
+
+		if names[parameter.Name] {
+			err = fmt.Errorf("the parameter '%s' is defined twice", parameter.Name)
+			return nil, definitionError(filePath, content, offset, err)
+		}
+
+		names[parameter.Name] = true
+		parameters = append(parameters, parameter)
+		offset += len(raw)
+	}
+
+	return parameters, nil
+}
+
+// parseDefinition validates the definition and converts it to the parameter.
+func parseDefinition(raw []byte) (Parameter, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	var definition parameterDefinition
+	err := decoder.Decode(&definition)
+
+	if err != nil {
+		return Parameter{}, err
+	}
+
+	if definition.NodeID == "" {
+		return Parameter{}, fmt.Errorf("the nodeId is missing")
+	}
+
+	if _, err = parseNodeID(definition.NodeID); err != nil {
+		return Parameter{}, fmt.Errorf("invalid nodeId '%s': %s", definition.NodeID, err)
+	}
+
+	if definition.DataType != "" && !isBuiltinDataType(definition.DataType) {
+		return Parameter{}, fmt.Errorf("unknown dataType '%s'", definition.DataType)
+	}
+
+	for _, bounds := range []*data.Bounds{definition.Bounds, definition.Limits} {
+		if bounds != nil && bounds.UpperBound < bounds.LowerBound {
+			return Parameter{}, fmt.Errorf("the lower bound exceeds the upper one")
+		}
+	}
+
+	parameter := Parameter{
+		Name:     definition.Name,
+		NodeID:   definition.NodeID,
+		Sampling: DefaultSampling(),
+		Writable: definition.Writable,
+		Limits:   definition.Limits,
+		Bounds:   definition.Bounds,
+		Probe:    definition.Probe,
+		Metadata: data.ParameterMetadata{
+			Unit:        definition.Unit,
+			Description: definition.Description,
+			DataType:    definition.DataType,
+		},
+	}
+
+	if parameter.Name == "" {
+		parameter.Name = parameterNameFromNodeID(definition.NodeID)
+	}
+
+	if definition.MaxAge != "" {
+		err = parameter.parseOption("maxage=" + definition.MaxAge)
+
+		if err != nil {
+			return Parameter{}, err
+		}
+	}
+
+	if sampling := definition.Sampling; sampling != nil {
+		options := make([]string, 0)
+
+		if sampling.Interval != "" {
+			options = append(options, "sampling="+sampling.Interval)
+		}
+
+		if sampling.Deadband != "" {
+			options = append(options, "deadband="+sampling.Deadband)
+		}
+
+		if sampling.Trigger != "" {
+			options = append(options, "trigger="+sampling.Trigger)
+		}
+
+		for _, option := range options {
+			err = parameter.Sampling.parseOption(option)
+
+			if err != nil {
+				return Parameter{}, fmt.Errorf("parameter '%s': %s", parameter.Name, err)
+			}
+		}
+
+		if sampling.QueueSize != nil {
+			parameter.Sampling.QueueSize = *sampling.QueueSize
+		}
+
+		if sampling.DiscardOldest != nil {
+			parameter.Sampling.DiscardOldest = *sampling.DiscardOldest
+		}
+
+		// Check the deadband and trigger names.
+		if _, err = parameter.Sampling.filter(); err != nil {
+			return Parameter{}, err
+		}
+	}
+
+	return parameter, nil
+}
+
+// definitionError adds the file name and the line number to the error of the parameter
+// defined at the offset. The line of the invalid field is used if it can be found.
+func definitionError(filePath string, content []byte, offset int, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset += int(e.Offset)
+
+	case *json.UnmarshalTypeError:
+		offset += int(e.Offset)
+
+	default:
+		// Unknown fields are reported without the offset, so find the field itself.
+		const prefix = "json: unknown field "
+
+		if message := err.Error(); strings.HasPrefix(message, prefix) {
+			if index := bytes.Index(content[offset:], []byte(strings.TrimPrefix(message, prefix))); index >= 0 {
+				offset += index
+			}
+		}
+	}
+
+	if offset > len(content) {
+		offset = len(content)
+	}
+
+	line := bytes.Count(content[:offset], []byte("\n")) + 1
+
+	return fmt.Errorf("%s:%d: %s", filePath, line, err)
+}
+
+// isBuiltinDataType returns true if the name is the name of the built-in OPC UA data type.
+func isBuiltinDataType(name string) bool {
+	for id := ua.TypeIDBoolean; id <= ua.TypeIDDiagnosticInfo; id++ {
+		if strings.TrimPrefix(id.String(), "TypeID") == name {
+			return true
+		}
+	}
+
+	return false
+}
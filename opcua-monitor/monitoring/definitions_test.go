@@ -0,0 +1,120 @@
+package monitoring
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTempFile writes the content to the file in a new temporary directory. It returns
+// the path of the file and the function removing the directory after the test.
+func writeTempFile(t *testing.T, name, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "monitoring")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := filepath.Join(dir, name)
+	err = ioutil.WriteFile(filePath, []byte(content), 0666)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return filePath, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLoadParameterDefinitions(t *testing.T) {
+	filePath, remove := writeTempFile(t, "parameters.json", `[
+	{
+		"nodeId": "ns=3;s=Temperature",
+		"unit": "°C",
+		"bounds": {"lowerBound": 30, "upperBound": 40},
+		"sampling": {"interval": "100ms", "deadband": "absolute:0.1", "queueSize": 5},
+		"maxAge": "30s"
+	},
+	{
+		"nodeId": "nsu=urn:plc:project;i=1001",
+		"name": "Setpoint",
+		"writable": true,
+		"limits": {"lowerBound": 20, "upperBound": 40}
+	}
+]`)
+	defer remove()
+
+	parameters, err := LoadParameterDefinitions(filePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parameters) != 2 {
+		t.Fatalf("LoadParameterDefinitions returned %d parameters, want 2", len(parameters))
+	}
+
+	temperature := parameters[0]
+
+	if temperature.Name != "Temperature" || temperature.Metadata.Unit != "°C" || temperature.MaxAge != 30*time.Second {
+		t.Errorf("unexpected parameter %+v", temperature)
+	}
+
+	if temperature.Bounds == nil || temperature.Bounds.LowerBound != 30 || temperature.Bounds.UpperBound != 40 {
+		t.Errorf("unexpected bounds %+v", temperature.Bounds)
+	}
+
+	sampling := temperature.Sampling
+
+	if sampling.Interval != 100*time.Millisecond || sampling.QueueSize != 5 ||
+		sampling.Deadband != "absolute" || sampling.DeadbandValue != 0.1 {
+		t.Errorf("unexpected sampling %+v", sampling)
+	}
+
+	setpoint := parameters[1]
+
+	if setpoint.Name != "Setpoint" || !setpoint.Writable || setpoint.Limits == nil {
+		t.Errorf("unexpected parameter %+v", setpoint)
+	}
+}
+
+func TestLoadParameterDefinitionsErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		// message is the part of the error identifying the invalid line.
+		message string
+	}{
+		{`{"nodeId": "ns=3;s=Temperature"}`, ":1: the definitions must be a list"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Temperature\"},\n\t{\"name\": \"Level\"}\n]", ":3: the nodeId is missing"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Temperature\",\n\t \"color\": \"red\"}\n]", ":3: json: unknown field"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Level\"},\n\t{\"nodeId\": \"ns=3;s=Level\"}\n]", ":3: the parameter 'Level' is defined twice"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Level\",\n\t \"writable\": \"yes\"}\n]", ":3: json: cannot unmarshal"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Level\", \"dataType\": \"Decimal128\"}\n]", ":2: unknown dataType"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Level\",\n\t \"bounds\": {\"lowerBound\": 2, \"upperBound\": 1}}\n]",
+			":2: the lower bound exceeds the upper one"},
+		{"[\n\n\t{\"nodeId\": \"ns=3;s=Level\", \"sampling\": {\"deadband\": \"percent:150\"}}\n]",
+			":3: parameter 'Level': Invalid deadband"},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Level\", \"maxAge\": \"soon\"}\n]", ":2: "},
+		{"[\n\t{\"nodeId\": \"ns=3;s=Level\"},\n\t{\"nodeId\": \n]", ":4: invalid character"},
+	}
+
+	for i, test := range tests {
+		filePath, remove := writeTempFile(t, "parameters.json", test.content)
+		_, err := LoadParameterDefinitions(filePath)
+		remove()
+
+		if err == nil {
+			t.Errorf("#%d: LoadParameterDefinitions succeeded, want an error", i)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), filePath+test.message) {
+			t.Errorf("#%d: LoadParameterDefinitions error %q doesn't contain %q", i, err, test.message)
+		}
+	}
+}
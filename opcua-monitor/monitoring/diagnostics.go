@@ -0,0 +1,206 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"biocad-opcua/shared"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// diagnosticsInterval is the interval of reading the status of the server and the subscription.
+const diagnosticsInterval = 10 * time.Second
+
+// EnableDiagnostics makes the monitor put the diagnostics of the server to the store
+// in addition to sending them to the subscribers, so the latest ones can be requested.
+func (monitor *OpcuaMonitor) EnableDiagnostics(store shared.DiagnosticsStore) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.diagnosticsStore = store
+}
+
+// countNotifications records the values and the events received from the server.
+func (monitor *OpcuaMonitor) countNotifications(count int) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.notifications += count
+}
+
+// resetDiagnostics starts counting the notifications and the messages anew.
+// The monitor must be locked by the caller.
+func (monitor *OpcuaMonitor) resetDiagnostics() {
+	monitor.notifications = 0
+	monitor.keepAlives = 0
+	monitor.missedMessages = 0
+	monitor.diagnosed = time.Now()
+}
+
+// sendDiagnostics sends the counters of the monitor along with the status of the server
+// and the diagnostics of the subscription to the subscribers. The round trip is measured
+// on the request reading the status, not on the publish requests. The subscription diagnostics
+// are skipped if the server doesn't provide them, e.g. when the diagnostics of the server are disabled.
+func (monitor *OpcuaMonitor) sendDiagnostics() {
+	monitor.synchronizer.Lock()
+	connection := monitor.connection
+	store := monitor.diagnosticsStore
+	now := time.Now()
+	diagnostics := data.ServerDiagnostics{
+		Server:                 monitor.config.Name,
+		Endpoint:               monitor.endpoint,
+		NotificationsPerSecond: float64(monitor.notifications) / now.Sub(monitor.diagnosed).Seconds(),
+		KeepAlives:             monitor.keepAlives,
+		MissedMessages:         monitor.missedMessages,
+		Timestamp:              now,
+	}
+
+	var subscriptionID uint32
+
+	if monitor.subscription != nil && !monitor.config.polling() {
+		subscriptionID = monitor.subscription.SubscriptionID
+	}
+
+	monitor.resetDiagnostics()
+	monitor.synchronizer.Unlock()
+
+	nodes := []*ua.ReadValueID{
+		{
+			NodeID:       ua.NewNumericNodeID(0, id.Server_ServerStatus),
+			AttributeID:  ua.AttributeIDValue,
+			DataEncoding: &ua.QualifiedName{},
+		},
+	}
+
+	if subscriptionID != 0 {
+		nodes = append(nodes, &ua.ReadValueID{
+			NodeID:       ua.NewNumericNodeID(0, id.Server_ServerDiagnostics_SubscriptionDiagnosticsArray),
+			AttributeID:  ua.AttributeIDValue,
+			DataEncoding: &ua.QualifiedName{},
+		})
+	}
+
+	// The publish requests are held by the server until there are notifications,
+	// so the round trip is measured with the status request.
+	start := time.Now()
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead:        nodes,
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	})
+	diagnostics.RoundTrip = time.Since(start)
+
+	if err == nil && len(res.Results) != len(nodes) {
+		err = fmt.Errorf("The server returned %d results for %d nodes", len(res.Results), len(nodes))
+	}
+
+	if err == nil {
+		err = readServerStatus(res.Results[0], &diagnostics)
+	}
+
+	monitor.handleDiagnosticsError(err)
+
+	if err != nil {
+		return
+	}
+
+	if subscriptionID != 0 {
+		diagnostics.Subscription = findSubscriptionDiagnostics(res.Results[1], subscriptionID)
+	}
+
+	monitor.fanout.SendMeasurement(diagnostics)
+
+	if store != nil {
+		err = store.SetServerDiagnostics(diagnostics)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't store the diagnostics of the server '%s': %s", monitor.config.Name, err)
+		}
+	}
+}
+
+// readServerStatus copies the status of the server to the diagnostics.
+func readServerStatus(value *ua.DataValue, diagnostics *data.ServerDiagnostics) error {
+	if value.Status != ua.StatusOK {
+		return fmt.Errorf("Couldn't read the server status: %s", statusName(value.Status))
+	}
+
+	if value.Value == nil {
+		return fmt.Errorf("The server returned no status")
+	}
+
+	object, ok := value.Value.Value().(*ua.ExtensionObject)
+
+	if !ok || object == nil {
+		return fmt.Errorf("The server status is not a structure")
+	}
+
+	status, ok := object.Value.(*ua.ServerStatusDataType)
+
+	if !ok {
+		return fmt.Errorf("The server status is not a ServerStatusDataType")
+	}
+
+	diagnostics.State = strings.TrimPrefix(status.State.String(), "ServerState")
+	diagnostics.StartTime = status.StartTime
+	diagnostics.CurrentTime = status.CurrentTime
+
+	if info := status.BuildInfo; info != nil {
+		diagnostics.ProductName = info.ProductName
+		diagnostics.ManufacturerName = info.ManufacturerName
+		diagnostics.SoftwareVersion = info.SoftwareVersion
+		diagnostics.BuildNumber = info.BuildNumber
+	}
+
+	return nil
+}
+
+// findSubscriptionDiagnostics returns the diagnostics of the subscription with the given ID
+// from the diagnostics array of the server or nil if the server doesn't provide them.
+func findSubscriptionDiagnostics(value *ua.DataValue, subscriptionID uint32) *data.SubscriptionDiagnostics {
+	if value.Status != ua.StatusOK || value.Value == nil {
+		return nil
+	}
+
+	objects, ok := value.Value.Value().([]*ua.ExtensionObject)
+
+	if !ok {
+		return nil
+	}
+
+	for _, object := range objects {
+		if object == nil {
+			continue
+		}
+
+		stats, ok := object.Value.(*ua.SubscriptionDiagnosticsDataType)
+
+		if !ok || stats.SubscriptionID != subscriptionID {
+			continue
+		}
+
+		return &data.SubscriptionDiagnostics{
+			SubscriptionID:          stats.SubscriptionID,
+			PublishRequests:         stats.PublishRequestCount,
+			LatePublishRequests:     stats.LatePublishRequestCount,
+			RepublishRequests:       stats.RepublishRequestCount,
+			DataChangeNotifications: stats.DataChangeNotificationsCount,
+			EventNotifications:      stats.EventNotificationsCount,
+			KeepAliveCount:          stats.CurrentKeepAliveCount,
+			UnacknowledgedMessages:  stats.UnacknowledgedMessageCount,
+			DiscardedMessages:       stats.DiscardedMessageCount,
+			QueueOverflows:          stats.MonitoringQueueOverflowCount,
+			NextSequenceNumber:      stats.NextSequenceNumber,
+		}
+	}
+
+	return nil
+}
+
+func (monitor *OpcuaMonitor) handleDiagnosticsError(err error) {
+	if err != nil {
+		monitor.logger.Printf("Couldn't obtain the diagnostics of the server '%s': %s", monitor.config.Name, err)
+	}
+}
@@ -0,0 +1,120 @@
+package monitoring
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+// SecurityAuto is the security policy or mode accepting any policy or mode
+// offered by the server. The most secure endpoint is selected then.
+const SecurityAuto = "Auto"
+
+// DiscoverEndpoints returns the endpoints offered by the server.
+func DiscoverEndpoints(endpoint string) ([]*ua.EndpointDescription, error) {
+	endpoints, err := opcua.GetEndpoints(endpoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("The server offers no endpoints")
+	}
+
+	return endpoints, nil
+}
+
+// SelectEndpoint returns the endpoint with the highest security level
+// matching the security settings and supporting the user identity token.
+func (config ConnectionConfig) SelectEndpoint(endpoints []*ua.EndpointDescription) (*ua.EndpointDescription, error) {
+	tokenType := config.Identity.tokenType()
+	var (
+		selected  *ua.EndpointDescription
+		rejection error
+	)
+
+	for _, endpoint := range endpoints {
+		if !supportsTokenType(endpoint, tokenType) {
+			continue
+		}
+
+		err := config.Security.check(endpoint)
+
+		if err != nil {
+			rejection = err
+			continue
+		}
+
+		if selected == nil || endpoint.SecurityLevel > selected.SecurityLevel {
+			selected = endpoint
+		}
+	}
+
+	if selected == nil && rejection != nil {
+		return nil, rejection
+	}
+
+	if selected == nil {
+		return nil, fmt.Errorf("The server has no endpoint with security policy '%s' and mode '%s' "+
+			"accepting the %s user token", config.Security.Policy, config.Security.Mode, tokenTypeName(tokenType))
+	}
+
+	return selected, nil
+}
+
+// check returns the reason the secure channel through the endpoint
+// doesn't satisfy the security settings or nil if it does.
+func (security SecurityConfig) check(endpoint *ua.EndpointDescription) error {
+	if !strings.EqualFold(security.Policy, SecurityAuto) &&
+		endpoint.SecurityPolicyURI != securityPolicyURI(security.Policy) {
+		return fmt.Errorf("The security policy doesn't match")
+	}
+
+	if !strings.EqualFold(security.Mode, SecurityAuto) && endpoint.SecurityMode != securityMode(security.Mode) {
+		return fmt.Errorf("The security mode doesn't match")
+	}
+
+	if endpoint.SecurityMode == ua.MessageSecurityModeNone {
+		return nil
+	}
+
+	// The secure channel can't be established without the application instance certificate.
+	if security.CertificateFile == "" {
+		return fmt.Errorf("The application instance certificate is required by the endpoint")
+	}
+
+	return security.verifyServerCertificate(endpoint.ServerCertificate)
+}
+
+// supportsTokenType checks if the endpoint accepts the user identity token of the given type.
+func supportsTokenType(endpoint *ua.EndpointDescription, tokenType ua.UserTokenType) bool {
+	for _, token := range endpoint.UserIdentityTokens {
+		if token.TokenType == tokenType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FormatEndpoint describes the endpoint in a single line.
+func FormatEndpoint(endpoint *ua.EndpointDescription) string {
+	tokens := make([]string, 0, len(endpoint.UserIdentityTokens))
+
+	for _, token := range endpoint.UserIdentityTokens {
+		tokens = append(tokens, tokenTypeName(token.TokenType))
+	}
+
+	return fmt.Sprintf("%s %s/%s (security level %d, user tokens: %s)", endpoint.EndpointURL,
+		strings.TrimPrefix(endpoint.SecurityPolicyURI, ua.SecurityPolicyURIPrefix),
+		strings.TrimPrefix(endpoint.SecurityMode.String(), "MessageSecurityMode"),
+		endpoint.SecurityLevel, strings.Join(tokens, ", "))
+}
+
+// tokenTypeName returns the name of the user identity token type, e.g. UserName.
+func tokenTypeName(tokenType ua.UserTokenType) string {
+	return strings.TrimPrefix(tokenType.String(), "UserTokenType")
+}
@@ -0,0 +1,83 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// testEndpoint creates the endpoint description accepting the user token types.
+func testEndpoint(policy string, mode ua.MessageSecurityMode, level uint8,
+	tokenTypes ...ua.UserTokenType) *ua.EndpointDescription {
+	endpoint := &ua.EndpointDescription{
+		EndpointURL:       "opc.tcp://localhost:4840",
+		SecurityPolicyURI: ua.SecurityPolicyURIPrefix + policy,
+		SecurityMode:      mode,
+		SecurityLevel:     level,
+	}
+
+	for _, tokenType := range tokenTypes {
+		endpoint.UserIdentityTokens = append(endpoint.UserIdentityTokens, &ua.UserTokenPolicy{TokenType: tokenType})
+	}
+
+	return endpoint
+}
+
+func TestSelectEndpoint(t *testing.T) {
+	none := testEndpoint("None", ua.MessageSecurityModeNone, 0, ua.UserTokenTypeAnonymous)
+	sign := testEndpoint("Basic256Sha256", ua.MessageSecurityModeSign, 10,
+		ua.UserTokenTypeAnonymous, ua.UserTokenTypeUserName)
+	encrypt := testEndpoint("Basic256Sha256", ua.MessageSecurityModeSignAndEncrypt, 20, ua.UserTokenTypeUserName)
+	legacy := testEndpoint("Basic128Rsa15", ua.MessageSecurityModeSignAndEncrypt, 5, ua.UserTokenTypeAnonymous)
+	endpoints := []*ua.EndpointDescription{none, sign, encrypt, legacy}
+
+	tests := []struct {
+		name     string
+		security SecurityConfig
+		identity string
+		want     *ua.EndpointDescription
+	}{
+		{"no security", SecurityConfig{Policy: "None", Mode: "None"}, "Anonymous", none},
+		{"exact match", SecurityConfig{Policy: "Basic256Sha256", Mode: "Sign", CertificateFile: "cert.pem"},
+			"Anonymous", sign},
+		{"most secure", SecurityConfig{Policy: "Auto", Mode: "Auto", CertificateFile: "cert.pem"}, "UserName", encrypt},
+		{"most secure for the token", SecurityConfig{Policy: "Auto", Mode: "Auto", CertificateFile: "cert.pem"},
+			"Anonymous", sign},
+		{"any mode", SecurityConfig{Policy: "Basic128Rsa15", Mode: "Auto", CertificateFile: "cert.pem"},
+			"Anonymous", legacy},
+		{"no certificate", SecurityConfig{Policy: "Auto", Mode: "Auto"}, "Anonymous", none},
+		{"certificate required", SecurityConfig{Policy: "Basic256Sha256", Mode: "Auto"}, "Anonymous", nil},
+		{"token not accepted", SecurityConfig{Policy: "None", Mode: "None"}, "UserName", nil},
+		{"unknown token", SecurityConfig{Policy: "Auto", Mode: "Auto", CertificateFile: "cert.pem"},
+			"Certificate", nil},
+		{"policy not offered", SecurityConfig{Policy: "Aes256_Sha256_RsaPss", Mode: "Auto",
+			CertificateFile: "cert.pem"}, "Anonymous", nil},
+	}
+
+	for _, test := range tests {
+		config := ConnectionConfig{
+			Security: test.security,
+			Identity: IdentityConfig{Type: test.identity},
+		}
+
+		selected, err := config.SelectEndpoint(endpoints)
+
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%s: SelectEndpoint selected %s, want an error", test.name, FormatEndpoint(selected))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: SelectEndpoint failed: %s", test.name, err)
+			continue
+		}
+
+		if selected != test.want {
+			t.Errorf("%s: SelectEndpoint selected %s, want %s", test.name,
+				FormatEndpoint(selected), FormatEndpoint(test.want))
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// eventField is a field of the event selected from the server.
+type eventField struct {
+	typeID uint32
+	path   []string
+}
+
+// Indexes of the event fields in the event notifications.
+const (
+	eventTypeField = iota
+	eventSeverityField
+	eventMessageField
+	eventSourceField
+	eventTimeField
+	eventConditionField
+	eventActiveField
+)
+
+// eventFields are the fields of the events in the order of the indexes above.
+var eventFields = []eventField{
+	{id.BaseEventType, []string{"EventType"}},
+	{id.BaseEventType, []string{"Severity"}},
+	{id.BaseEventType, []string{"Message"}},
+	{id.BaseEventType, []string{"SourceName"}},
+	{id.BaseEventType, []string{"Time"}},
+	{id.ConditionType, []string{"ConditionName"}},
+	{id.AlarmConditionType, []string{"ActiveState", "Id"}},
+}
+
+// MonitorEvents makes the monitor receive the alarms and events of the
+// notifier node of the server if it's configured. The events are converted
+// to the alerts and sent to the subscribers along with the parameters.
+func (monitor *OpcuaMonitor) MonitorEvents() error {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	notifier := monitor.config.EventNotifier
+
+	if notifier == "" || monitor.eventsMonitored {
+		return nil
+	}
+
+	if monitor.config.polling() {
+		monitor.logger.Println("The events can't be received in the polling mode of the server", monitor.config.Name)
+		return nil
+	}
+
+	// The events will be monitored as soon as the connection is restored.
+	if monitor.connected {
+		err := monitor.monitorEvents(monitor.handleCounter, notifier)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	monitor.eventsMonitored = true
+	monitor.eventHandle = monitor.handleCounter
+	monitor.handleCounter++
+
+	return nil
+}
+
+// monitorEvents creates a monitored item for the events of the notifier node.
+func (monitor *OpcuaMonitor) monitorEvents(handle uint32, notifier string) error {
+	id, err := resolveNodeID(notifier, monitor.namespaces)
+	monitor.handleEventsError(err)
+
+	if err != nil {
+		return err
+	}
+
+	request := &ua.MonitoredItemCreateRequest{
+		ItemToMonitor: &ua.ReadValueID{
+			NodeID:       id,
+			AttributeID:  ua.AttributeIDEventNotifier,
+			DataEncoding: &ua.QualifiedName{},
+		},
+		MonitoringMode: ua.MonitoringModeReporting,
+		RequestedParameters: &ua.MonitoringParameters{
+			ClientHandle:  handle,
+			QueueSize:     100,
+			DiscardOldest: true,
+			Filter:        eventFilter(),
+		},
+	}
+
+	res, err := monitor.subscription.Monitor(ua.TimestampsToReturnBoth, request)
+	monitor.handleEventsError(err)
+
+	if err != nil {
+		return err
+	}
+
+	if len(res.Results) == 0 {
+		err = fmt.Errorf("The server returned no result")
+		monitor.handleEventsError(err)
+
+		return err
+	}
+
+	if status := res.Results[0].StatusCode; status != ua.StatusOK {
+		err = fmt.Errorf("Bad response status: %s", statusName(status))
+		monitor.handleEventsError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// eventFilter returns the filter selecting the event fields.
+func eventFilter() *ua.ExtensionObject {
+	clauses := make([]*ua.SimpleAttributeOperand, len(eventFields))
+
+	for i, field := range eventFields {
+		path := make([]*ua.QualifiedName, len(field.path))
+
+		for j, name := range field.path {
+			path[j] = &ua.QualifiedName{Name: name}
+		}
+
+		clauses[i] = &ua.SimpleAttributeOperand{
+			TypeDefinitionID: ua.NewNumericNodeID(0, field.typeID),
+			BrowsePath:       path,
+			AttributeID:      ua.AttributeIDValue,
+		}
+	}
+
+	return &ua.ExtensionObject{
+		TypeID:       ua.NewFourByteExpandedNodeID(0, id.EventFilter_Encoding_DefaultBinary),
+		EncodingMask: ua.ExtensionObjectBinary,
+		Value: &ua.EventFilter{
+			SelectClauses: clauses,
+			WhereClause:   &ua.ContentFilter{},
+		},
+	}
+}
+
+// sendEventsToFanout converts the events to the alerts and sends them to the subscribers.
+func (monitor *OpcuaMonitor) sendEventsToFanout(message *ua.EventNotificationList) {
+	for _, event := range message.Events {
+		if len(event.EventFields) < len(eventFields) {
+			monitor.logger.Println("Received an event with missing fields")
+			continue
+		}
+
+		monitor.fanout.SendMeasurement(monitor.alertFromEvent(event.EventFields))
+	}
+}
+
+// alertFromEvent converts the selected event fields to the alert.
+func (monitor *OpcuaMonitor) alertFromEvent(fields []*ua.Variant) data.Alert {
+	alert := data.Alert{
+		Server: monitor.config.Name,
+		Type:   data.AlertEvent,
+	}
+
+	if eventType, ok := eventValue(fields, eventTypeField).(*ua.NodeID); ok {
+		alert.EventType = eventType.String()
+	}
+
+	if severity, ok := eventValue(fields, eventSeverityField).(uint16); ok {
+		alert.Severity = severity
+	}
+
+	if message, ok := eventValue(fields, eventMessageField).(*ua.LocalizedText); ok {
+		alert.Message = message.Text
+	}
+
+	if source, ok := eventValue(fields, eventSourceField).(string); ok {
+		alert.Parameter = source
+	}
+
+	if timestamp, ok := eventValue(fields, eventTimeField).(time.Time); ok {
+		alert.Timestamp = timestamp
+	}
+
+	if condition, ok := eventValue(fields, eventConditionField).(string); ok {
+		alert.Condition = condition
+	}
+
+	if active, ok := eventValue(fields, eventActiveField).(bool); ok {
+		alert.Active = active
+	}
+
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+
+	return alert
+}
+
+// eventValue returns the value of the event field or nil if the server hasn't set it.
+func eventValue(fields []*ua.Variant, index int) interface{} {
+	if fields[index] == nil {
+		return nil
+	}
+
+	return fields[index].Value()
+}
+
+func (monitor *OpcuaMonitor) handleEventsError(err error) {
+	if err != nil {
+		monitor.logger.Println("Couldn't subscribe to the events:", err)
+	}
+}
@@ -0,0 +1,23 @@
+package monitoring
+
+import (
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Register the extension objects the monitor receives from the server
+// which gopcua can't decode by itself.
+func init() {
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(0, id.EventNotificationList_Encoding_DefaultBinary),
+		new(ua.EventNotificationList))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(0, id.EventFilterResult_Encoding_DefaultBinary),
+		new(ua.EventFilterResult))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(0, id.StatusChangeNotification_Encoding_DefaultBinary),
+		new(ua.StatusChangeNotification))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(0, id.Argument_Encoding_DefaultBinary),
+		new(ua.Argument))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(0, id.EUInformation_Encoding_DefaultBinary),
+		new(ua.EUInformation))
+	ua.RegisterExtensionObject(ua.NewNumericNodeID(0, id.Range_Encoding_DefaultBinary),
+		new(ua.Range))
+}
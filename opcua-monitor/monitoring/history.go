@@ -0,0 +1,221 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"biocad-opcua/shared"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// maxBackfillPeriod limits how far back the monitor reads the history of the parameters.
+	maxBackfillPeriod = 24 * time.Hour
+	// maxHistoryValuesPerRequest is the number of values the server returns
+	// for a parameter in a single response.
+	maxHistoryValuesPerRequest = 1000
+)
+
+// EnableBackfill makes the monitor fill the gaps in the stored data with the values
+// from the history of the server each time the connection is established.
+// The gaps caused by the outages of the database are filled with BackfillPeriod.
+// The values are sent to the channel only and aren't published as live data.
+// The backfill is performed only for the servers supporting historical access.
+func (monitor *OpcuaMonitor) EnableBackfill(store shared.HistoryStore, channel chan<- data.Measurement) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.historyStore = store
+	monitor.historyFanout.AddChannel(channel)
+}
+
+// startBackfill starts filling the gaps up to the current time if the backfill is enabled.
+func (monitor *OpcuaMonitor) startBackfill() {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if !monitor.config.HistoryAccess || monitor.historyStore == nil || !monitor.connected {
+		return
+	}
+
+	parameters := make([]Parameter, 0, len(monitor.parameters))
+
+	for _, parameter := range monitor.parameters {
+		parameters = append(parameters, parameter)
+	}
+
+	go monitor.backfill(monitor.connection, monitor.namespaces, parameters, time.Now())
+}
+
+// BackfillPeriod fills the gap in the stored data between the times with the values
+// from the history of the server, e.g. when the values received in the meantime
+// couldn't be written to the time-series database. The values already stored
+// in the period are written again. The backfill is performed only for the servers
+// supporting historical access, the gaps of other servers remain.
+func (monitor *OpcuaMonitor) BackfillPeriod(since, until time.Time) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if !monitor.config.HistoryAccess || monitor.historyStore == nil || !monitor.connected {
+		return
+	}
+
+	parameters := make([]Parameter, 0, len(monitor.parameters))
+
+	for _, parameter := range monitor.parameters {
+		parameters = append(parameters, parameter)
+	}
+
+	if until.Sub(since) > maxBackfillPeriod {
+		since = until.Add(-maxBackfillPeriod)
+	}
+
+	go monitor.backfillPeriod(monitor.connection, monitor.namespaces, parameters, since, until)
+}
+
+// backfillPeriod reads the values of the parameters between the times from the history of the server.
+func (monitor *OpcuaMonitor) backfillPeriod(connection *opcua.Client, namespaces []string,
+	parameters []Parameter, since, until time.Time) {
+	for _, parameter := range parameters {
+		count, err := monitor.backfillParameter(connection, namespaces, parameter, since, until)
+
+		if err != nil {
+			monitor.handleBackfillError(parameter, err)
+			continue
+		}
+
+		monitor.logger.Printf("Backfilled %d values of the parameter '%s' since %v until %v",
+			count, parameter.Name, since, until)
+	}
+}
+
+// backfill reads the values of the parameters from the history of the server
+// since the last stored value of each parameter until the given time.
+func (monitor *OpcuaMonitor) backfill(connection *opcua.Client, namespaces []string,
+	parameters []Parameter, until time.Time) {
+	for _, parameter := range parameters {
+		since, err := monitor.historyStore.LastParameterTimestamp(monitor.config.Name, parameter.Name)
+
+		if err != nil {
+			monitor.handleBackfillError(parameter, err)
+			continue
+		}
+
+		// There is no gap if the parameter has never been stored or has just been stored.
+		if since.IsZero() || until.Sub(since) < monitor.interval {
+			continue
+		}
+
+		if until.Sub(since) > maxBackfillPeriod {
+			since = until.Add(-maxBackfillPeriod)
+		}
+
+		// The last stored value isn't read again.
+		count, err := monitor.backfillParameter(connection, namespaces, parameter,
+			since.Add(time.Microsecond), until)
+
+		if err != nil {
+			monitor.handleBackfillError(parameter, err)
+			continue
+		}
+
+		monitor.logger.Printf("Backfilled %d values of the parameter '%s' since %v", count, parameter.Name, since)
+	}
+}
+
+// backfillParameter reads the raw values of the parameter from the history of the server
+// and sends them to the history subscribers. It returns the number of the values read.
+func (monitor *OpcuaMonitor) backfillParameter(connection *opcua.Client, namespaces []string,
+	parameter Parameter, since, until time.Time) (int, error) {
+	id, err := resolveNodeID(parameter.NodeID, namespaces)
+
+	if err != nil {
+		return 0, err
+	}
+
+	node := &ua.HistoryReadValueID{
+		NodeID:       id,
+		DataEncoding: &ua.QualifiedName{},
+	}
+	details := &ua.ReadRawModifiedDetails{
+		StartTime:        since,
+		EndTime:          until,
+		NumValuesPerNode: maxHistoryValuesPerRequest,
+	}
+	count := 0
+
+	for {
+		res, err := connection.HistoryReadRawModified([]*ua.HistoryReadValueID{node}, details)
+
+		if err != nil {
+			return count, err
+		}
+
+		if len(res.Results) == 0 {
+			return count, fmt.Errorf("The server returned no result")
+		}
+
+		result := res.Results[0]
+
+		// The history may be unsupported or unavailable for the parameter.
+		if severity := uint32(result.StatusCode) >> 30; severity != 0 {
+			return count, fmt.Errorf("Couldn't read the history: %s", statusName(result.StatusCode))
+		}
+
+		if result.HistoryData != nil {
+			if history, ok := result.HistoryData.Value.(*ua.HistoryData); ok {
+				count += monitor.sendHistoryToFanout(parameter, history.DataValues)
+			}
+		}
+
+		if len(result.ContinuationPoint) == 0 {
+			return count, nil
+		}
+
+		node.ContinuationPoint = result.ContinuationPoint
+	}
+}
+
+// sendHistoryToFanout sends the historical values of the parameter to the history
+// subscribers. It returns the number of the values sent.
+func (monitor *OpcuaMonitor) sendHistoryToFanout(parameter Parameter, values []*ua.DataValue) int {
+	count := 0
+
+	for _, value := range values {
+		sample, err := sampleFromDataValue(value)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't read the historical value of the parameter '%s': %s",
+				parameter.Name, err)
+			continue
+		}
+
+		timestamp := sample.SourceTimestamp
+
+		if timestamp.IsZero() {
+			timestamp = sample.ServerTimestamp
+		}
+
+		// The value can't be placed in the time series without a timestamp.
+		if timestamp.IsZero() {
+			continue
+		}
+
+		monitor.historyFanout.SendMeasurement(data.ParametersState{
+			Server:     monitor.config.Name,
+			Timestamp:  timestamp,
+			Parameters: map[string]data.Sample{parameter.Name: sample},
+		})
+		count++
+	}
+
+	return count
+}
+
+func (monitor *OpcuaMonitor) handleBackfillError(parameter Parameter, err error) {
+	if err != nil {
+		monitor.logger.Printf("Couldn't backfill the parameter '%s': %s", parameter.Name, err)
+	}
+}
@@ -0,0 +1,110 @@
+package monitoring
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Environment variables the user credentials are read from
+// if the credential files are not specified.
+const (
+	UsernameEnv = "OPCUA_USERNAME"
+	PasswordEnv = "OPCUA_PASSWORD"
+)
+
+// IdentityConfig describes the user the monitor activates the session as.
+//
+// The credentials are never passed directly, only through the files
+// or the environment variables, so they don't leak into the process list.
+type IdentityConfig struct {
+	// Type is the user identity token type: Anonymous, UserName or Certificate.
+	Type string
+	// UsernameFile is the file containing the user name.
+	// The name is taken from OPCUA_USERNAME if the file is not specified.
+	UsernameFile string
+	// PasswordFile is the file containing the user password.
+	// The password is taken from OPCUA_PASSWORD if the file is not specified.
+	PasswordFile string
+	// CertificateFile is the user certificate in DER or PEM format.
+	// The token is signed with the application instance private key,
+	// so the certificate must be issued for the same key pair.
+	CertificateFile string
+}
+
+// tokenType returns the type of the user identity token.
+func (identity IdentityConfig) tokenType() ua.UserTokenType {
+	switch strings.ToLower(identity.Type) {
+	case "username":
+		return ua.UserTokenTypeUserName
+
+	case "certificate":
+		return ua.UserTokenTypeCertificate
+
+	default:
+		return ua.UserTokenTypeAnonymous
+	}
+}
+
+// option returns the client option to activate the session with the user identity token.
+func (identity IdentityConfig) option() (opcua.Option, error) {
+	switch identity.tokenType() {
+	case ua.UserTokenTypeUserName:
+		username, err := readSecret(identity.UsernameFile, UsernameEnv)
+
+		if err != nil {
+			return nil, err
+		}
+
+		password, err := readSecret(identity.PasswordFile, PasswordEnv)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return opcua.AuthUsername(username, password), nil
+
+	case ua.UserTokenTypeCertificate:
+		certificate, err := ioutil.ReadFile(identity.CertificateFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if block, _ := pem.Decode(certificate); block != nil {
+			certificate = block.Bytes
+		}
+
+		return opcua.AuthCertificate(certificate), nil
+
+	default:
+		return opcua.AuthAnonymous(), nil
+	}
+}
+
+// readSecret reads the secret from the file or, if the file
+// is not specified, from the environment variable.
+func readSecret(file, env string) (string, error) {
+	if file != "" {
+		contents, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(contents)), nil
+	}
+
+	value, ok := os.LookupEnv(env)
+
+	if !ok {
+		return "", fmt.Errorf("Neither the file nor the environment variable %s is set", env)
+	}
+
+	return value, nil
+}
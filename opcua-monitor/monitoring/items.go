@@ -0,0 +1,256 @@
+package monitoring
+
+import (
+	"biocad-opcua/shared"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// defaultMaxMonitoredItemsPerCall limits the number of the monitored items
+	// created with a single request if the server doesn't limit it.
+	defaultMaxMonitoredItemsPerCall = 1000
+	// itemRetryInterval is the interval of creating the monitored items again
+	// if the server has rejected them, e.g. the node hasn't been created yet.
+	itemRetryInterval = 30 * time.Second
+)
+
+// MonitorParameters makes the monitor receive updates of the parameters from the server.
+// The Address of the parameter is its NodeID. The parameters loaded by the monitor
+// keep their OPC UA settings, e.g. the sampling, and the other ones get the default settings.
+func (monitor *OpcuaMonitor) MonitorParameters(parameters []shared.Parameter) []shared.MonitorResult {
+	monitor.synchronizer.Lock()
+	definitions := make([]Parameter, 0, len(parameters))
+
+	for _, parameter := range parameters {
+		definitions = append(definitions, monitor.loaded[parameter.Name].withNeutral(parameter))
+	}
+	monitor.synchronizer.Unlock()
+
+	return monitor.monitorParameters(definitions)
+}
+
+// monitorParameters creates the monitored items for the parameters with as few
+// requests as the server allows. The parameters with invalid definitions are skipped,
+// and the parameters rejected by the server are monitored again at the interval
+// until the server accepts them.
+func (monitor *OpcuaMonitor) monitorParameters(parameters []Parameter) []shared.MonitorResult {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	results := make([]shared.MonitorResult, len(parameters))
+	handles := make([]uint32, 0, len(parameters))
+	indices := make(map[uint32]int, len(parameters))
+
+	for i, parameter := range parameters {
+		results[i].Parameter = parameter.neutral()
+		err := parameter.validate()
+
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		handle := monitor.handleCounter
+		monitor.handleCounter++
+
+		monitor.parameters[handle] = parameter
+		handles = append(handles, handle)
+		indices[handle] = i
+	}
+
+	// The parameters will be monitored as soon as the connection is restored.
+	if !monitor.connected {
+		return results
+	}
+
+	for handle, err := range monitor.monitorItems(handles) {
+		results[indices[handle]].Err = err
+		results[indices[handle]].Retrying = true
+	}
+
+	monitor.describeParameters()
+
+	return results
+}
+
+// validate checks if the parameter can be monitored on any server.
+func (parameter Parameter) validate() error {
+	_, err := parseNodeID(parameter.NodeID)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = parameter.Sampling.filter()
+
+	return err
+}
+
+// monitorItems creates the monitored items for the parameters with the given client
+// handles. It returns the reasons the items of the parameters couldn't be created.
+// The monitor must be locked by the caller. The lock is released while the server
+// creates the items, so the notifications aren't held up by the requests.
+func (monitor *OpcuaMonitor) monitorItems(handles []uint32) map[uint32]error {
+	errs := make(map[uint32]error)
+
+	// The parameters are read along with each other in the polling mode.
+	if monitor.config.polling() || len(handles) == 0 {
+		return errs
+	}
+
+	requests := make([]*ua.MonitoredItemCreateRequest, 0, len(handles))
+
+	for _, handle := range handles {
+		// The item is being created by another request already.
+		if monitor.creating[handle] {
+			continue
+		}
+
+		parameter := monitor.parameters[handle]
+		id, err := resolveNodeID(parameter.NodeID, monitor.namespaces)
+
+		if err != nil {
+			errs[handle] = err
+			continue
+		}
+
+		request := opcua.NewMonitoredItemCreateRequestWithDefaults(id, ua.AttributeIDValue, handle)
+		err = parameter.Sampling.apply(request.RequestedParameters)
+
+		if err != nil {
+			errs[handle] = err
+			continue
+		}
+
+		monitor.creating[handle] = true
+		requests = append(requests, request)
+	}
+
+	if len(requests) == 0 {
+		return errs
+	}
+
+	subscription := monitor.subscription
+	limit := monitor.itemsPerCall
+
+	monitor.synchronizer.Unlock()
+	items, failures := monitor.createItems(subscription, requests, limit)
+	monitor.synchronizer.Lock()
+
+	// The items of the lost subscription are recreated in the new one after reconnecting.
+	if monitor.subscription != subscription {
+		return errs
+	}
+
+	for _, request := range requests {
+		delete(monitor.creating, request.RequestedParameters.ClientHandle)
+	}
+
+	for handle, err := range failures {
+		errs[handle] = err
+	}
+
+	for handle, item := range items {
+		if _, ok := monitor.parameters[handle]; ok {
+			monitor.items[handle] = item
+			continue
+		}
+
+		// The parameter has been unmonitored while the item was being created.
+		err := monitor.unmonitorItem(item)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't delete the monitored item %d: %s", item, err)
+		}
+	}
+
+	return errs
+}
+
+// createItems creates the monitored items in the subscription with as few requests as the
+// server allows. It returns the server IDs of the created items and the reasons the other
+// items couldn't be created by the client handles. The monitor mustn't be locked.
+func (monitor *OpcuaMonitor) createItems(subscription *opcua.Subscription,
+	requests []*ua.MonitoredItemCreateRequest, limit int) (map[uint32]uint32, map[uint32]error) {
+	items := make(map[uint32]uint32, len(requests))
+	errs := make(map[uint32]error)
+
+	if limit <= 0 {
+		limit = defaultMaxMonitoredItemsPerCall
+	}
+
+	for start := 0; start < len(requests); start += limit {
+		end := start + limit
+
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		chunk := requests[start:end]
+		res, err := subscription.Monitor(ua.TimestampsToReturnBoth, chunk...)
+
+		if err == nil && len(res.Results) != len(chunk) {
+			err = fmt.Errorf("The server returned %d results for %d monitored items", len(res.Results), len(chunk))
+		}
+
+		monitor.handleSubscriptionError(err)
+
+		for i, request := range chunk {
+			handle := request.RequestedParameters.ClientHandle
+
+			switch {
+			case err != nil:
+				errs[handle] = err
+
+			case res.Results[i].StatusCode != ua.StatusOK:
+				errs[handle] = fmt.Errorf("The server rejected the monitored item: %s",
+					statusName(res.Results[i].StatusCode))
+
+			default:
+				// The server identifies the item by its own ID when it's deleted.
+				items[handle] = res.Results[i].MonitoredItemID
+			}
+		}
+	}
+
+	return items, errs
+}
+
+// retryItems creates the monitored items of the parameters rejected by the server again.
+func (monitor *OpcuaMonitor) retryItems() {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if !monitor.connected || monitor.config.polling() {
+		return
+	}
+
+	handles := make([]uint32, 0)
+
+	for handle := range monitor.parameters {
+		if _, ok := monitor.items[handle]; !ok {
+			handles = append(handles, handle)
+		}
+	}
+
+	if len(handles) == 0 {
+		return
+	}
+
+	errs := monitor.monitorItems(handles)
+
+	for _, handle := range handles {
+		if _, created := monitor.items[handle]; created {
+			monitor.logger.Printf("Started monitoring the parameter '%s'", monitor.parameters[handle].Name)
+		}
+	}
+
+	if len(errs) > 0 {
+		monitor.logger.Printf("Couldn't monitor %d parameters of the server '%s', retrying in %v",
+			len(errs), monitor.config.Name, itemRetryInterval)
+	}
+}
@@ -0,0 +1,32 @@
+package monitoring
+
+import (
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+// readOperationLimit reads the limit of the number of the operations in a single request
+// from the OperationLimits of the server. The limits are optional, so the fallback
+// is returned if the server doesn't provide the limit or doesn't limit the operations.
+func readOperationLimit(connection *opcua.Client, limitID uint32, fallback int) int {
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{
+				NodeID:       ua.NewNumericNodeID(0, limitID),
+				AttributeID:  ua.AttributeIDValue,
+				DataEncoding: &ua.QualifiedName{},
+			},
+		},
+	})
+
+	if err != nil || len(res.Results) == 0 || res.Results[0].Status != ua.StatusOK || res.Results[0].Value == nil {
+		return fallback
+	}
+
+	// Zero means there's no limit.
+	if limit, ok := res.Results[0].Value.Value().(uint32); ok && limit > 0 {
+		return int(limit)
+	}
+
+	return fallback
+}
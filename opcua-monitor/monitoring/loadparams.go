@@ -2,16 +2,29 @@ package monitoring
 
 import (
 	"bufio"
+	"fmt"
 	"os"
+	"strings"
 )
 
 // LoadParametersFromFile reads the file lines and loads
 // NodeIDs of the parameters we need to monitor on the server.
 //
-// Remark: it's impossible to browse parameters on the OPC UA
-// server with gopcua/opcua library, so I decided to read them from
-// a file.
-func LoadParametersFromFile(filePath string) ([]string, error) {
+// Each line contains the NodeID optionally followed by the sampling
+// and writing settings separated with spaces, e.g.:
+//
+//	ns=3;s=Volume sampling=10s deadband=percent:0.5
+//	ns=3;s=Setpoint writable limits=20:40
+//	ns=3;s=Level maxage=1m probe
+//
+// The namespace may be specified with its URI instead of the index, which
+// is resolved against the namespace array of the server after connecting:
+//
+//	nsu=urn:plc:project;s=Pressure
+//
+// Remark: it's a fallback for the servers which address space
+// can't be browsed. See BrowseParameters.
+func LoadParametersFromFile(filePath string) ([]Parameter, error) {
 	file, err := os.OpenFile(filePath, os.O_RDONLY, 0666)
 
 	if err != nil {
@@ -20,11 +33,33 @@ func LoadParametersFromFile(filePath string) ([]string, error) {
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
-	lines := make([]string, 0)
+	parameters := make([]Parameter, 0)
+	line := 0
 
 	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+		line++
+		tokens := strings.Fields(scanner.Text())
+
+		if len(tokens) == 0 {
+			continue
+		}
+
+		parameter := Parameter{
+			Name:     parameterNameFromNodeID(tokens[0]),
+			NodeID:   tokens[0],
+			Sampling: DefaultSampling(),
+		}
+
+		for _, option := range tokens[1:] {
+			err = parameter.parseOption(option)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: parameter '%s': %s", filePath, line, parameter.Name, err)
+			}
+		}
+
+		parameters = append(parameters, parameter)
 	}
 
-	return lines, nil
+	return parameters, scanner.Err()
 }
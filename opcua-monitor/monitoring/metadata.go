@@ -0,0 +1,259 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"biocad-opcua/shared"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// maxNodesPerDescription is the number of the parameters described with a single request.
+const maxNodesPerDescription = 100
+
+// Browse names of the properties of the analog items describing the parameter values.
+const (
+	engineeringUnitsProperty = "EngineeringUnits"
+	euRangeProperty          = "EURange"
+	instrumentRangeProperty  = "InstrumentRange"
+)
+
+// EnableMetadata makes the monitor read the engineering units and the ranges
+// of the parameters from the server when they're monitored for the first time
+// and put them to the store along with the metadata from the parameter source.
+// The initial alerting bounds are derived from the EURange of the parameters
+// unless they're defined in the parameter source.
+func (monitor *OpcuaMonitor) EnableMetadata(store shared.MetadataStore) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.metadataStore = store
+	monitor.describeParameters()
+}
+
+// startDescribing starts reading the metadata of the parameters which haven't been described.
+func (monitor *OpcuaMonitor) startDescribing() {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.describeParameters()
+}
+
+// describeParameters starts reading the metadata of the parameters which
+// haven't been described. The monitor must be locked by the caller.
+func (monitor *OpcuaMonitor) describeParameters() {
+	if monitor.metadataStore == nil || !monitor.connected {
+		return
+	}
+
+	parameters := make(map[uint32]Parameter)
+
+	for handle, parameter := range monitor.parameters {
+		if !monitor.described[handle] {
+			monitor.described[handle] = true
+			parameters[handle] = parameter
+		}
+	}
+
+	if len(parameters) > 0 {
+		go monitor.describe(monitor.connection, monitor.namespaces, parameters)
+	}
+}
+
+// describe reads the metadata of the parameters from the server and puts it to the store.
+// The parameters which couldn't be described are described again after reconnecting.
+func (monitor *OpcuaMonitor) describe(connection *opcua.Client, namespaces []string,
+	parameters map[uint32]Parameter) {
+	handles := make([]uint32, 0, maxNodesPerDescription)
+	nodes := make([]*ua.NodeID, 0, maxNodesPerDescription)
+	failed := make([]uint32, 0)
+
+	flush := func() {
+		descriptions, err := readMetadata(connection, nodes)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't read the metadata of the parameters of the server '%s': %s",
+				monitor.config.Name, err)
+			failed = append(failed, handles...)
+		}
+
+		for i, description := range descriptions {
+			monitor.storeMetadata(parameters[handles[i]], description)
+		}
+
+		handles = handles[:0]
+		nodes = nodes[:0]
+	}
+
+	for handle, parameter := range parameters {
+		id, err := resolveNodeID(parameter.NodeID, namespaces)
+
+		if err != nil {
+			failed = append(failed, handle)
+			continue
+		}
+
+		handles = append(handles, handle)
+		nodes = append(nodes, id)
+
+		if len(nodes) == maxNodesPerDescription {
+			flush()
+		}
+	}
+
+	if len(nodes) > 0 {
+		flush()
+	}
+
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	for _, handle := range failed {
+		delete(monitor.described, handle)
+	}
+}
+
+// storeMetadata puts the metadata of the parameter from the server completed with the one
+// from the parameter source to the store. The metadata of the parameter source takes precedence.
+func (monitor *OpcuaMonitor) storeMetadata(parameter Parameter, description data.ParameterMetadata) {
+	metadata := parameter.Metadata.Merge(description)
+
+	if metadata.IsEmpty() {
+		return
+	}
+
+	err := monitor.metadataStore.SetParameterMetadata(monitor.config.Name, parameter.Name, metadata)
+	monitor.handleMetadataError(parameter, err)
+
+	// The bounds of the parameter source have been stored already.
+	if parameter.Bounds != nil || description.EURange == nil {
+		return
+	}
+
+	set, err := monitor.metadataStore.SetInitialParameterBounds(monitor.config.Name, parameter.Name,
+		*description.EURange)
+	monitor.handleMetadataError(parameter, err)
+
+	if set {
+		monitor.logger.Printf("Derived the alerting bounds [%v, %v] of the parameter '%s' from its EURange",
+			description.EURange.LowerBound, description.EURange.UpperBound, parameter.Name)
+	}
+}
+
+// readMetadata reads the engineering units and the ranges of the nodes.
+// The metadata is empty for the nodes which aren't analog items.
+func readMetadata(connection *opcua.Client, nodes []*ua.NodeID) ([]data.ParameterMetadata, error) {
+	browse := make([]*ua.BrowseDescription, len(nodes))
+
+	for i, node := range nodes {
+		browse[i] = &ua.BrowseDescription{
+			NodeID:          node,
+			BrowseDirection: ua.BrowseDirectionForward,
+			ReferenceTypeID: ua.NewNumericNodeID(0, id.HasProperty),
+			IncludeSubtypes: true,
+			NodeClassMask:   uint32(ua.NodeClassVariable),
+			ResultMask:      uint32(ua.BrowseResultMaskBrowseName),
+		}
+	}
+
+	res, err := connection.Browse(&ua.BrowseRequest{
+		View: &ua.ViewDescription{
+			ViewID:    ua.NewTwoByteNodeID(0),
+			Timestamp: time.Now(),
+		},
+		RequestedMaxReferencesPerNode: maxReferencesPerNode,
+		NodesToBrowse:                 browse,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Results) != len(nodes) {
+		return nil, fmt.Errorf("The server returned %d results for %d nodes", len(res.Results), len(nodes))
+	}
+
+	// Read all the properties of interest with a single request.
+	owners := make([]int, 0)
+	names := make([]string, 0)
+	properties := make([]*ua.ReadValueID, 0)
+
+	for i, result := range res.Results {
+		if result.StatusCode != ua.StatusOK {
+			continue
+		}
+
+		for _, ref := range result.References {
+			switch ref.BrowseName.Name {
+			case engineeringUnitsProperty, euRangeProperty, instrumentRangeProperty:
+				owners = append(owners, i)
+				names = append(names, ref.BrowseName.Name)
+				properties = append(properties, &ua.ReadValueID{
+					NodeID:       ref.NodeID.NodeID,
+					AttributeID:  ua.AttributeIDValue,
+					DataEncoding: &ua.QualifiedName{},
+				})
+			}
+		}
+	}
+
+	descriptions := make([]data.ParameterMetadata, len(nodes))
+
+	if len(properties) == 0 {
+		return descriptions, nil
+	}
+
+	values, err := connection.Read(&ua.ReadRequest{
+		NodesToRead:        properties,
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values.Results) != len(properties) {
+		return nil, fmt.Errorf("The server returned %d values for %d properties", len(values.Results), len(properties))
+	}
+
+	for i, value := range values.Results {
+		if value.Status != ua.StatusOK || value.Value == nil {
+			continue
+		}
+
+		object, ok := value.Value.Value().(*ua.ExtensionObject)
+
+		if !ok || object == nil {
+			continue
+		}
+
+		description := &descriptions[owners[i]]
+
+		switch content := object.Value.(type) {
+		case *ua.EUInformation:
+			if content.DisplayName != nil {
+				description.Unit = content.DisplayName.Text
+			}
+
+		case *ua.Range:
+			bounds := &data.Bounds{LowerBound: content.Low, UpperBound: content.High}
+
+			if names[i] == euRangeProperty {
+				description.EURange = bounds
+			} else {
+				description.InstrumentRange = bounds
+			}
+		}
+	}
+
+	return descriptions, nil
+}
+
+func (monitor *OpcuaMonitor) handleMetadataError(parameter Parameter, err error) {
+	if err != nil {
+		monitor.logger.Printf("Couldn't store the metadata of the parameter '%s': %s", parameter.Name, err)
+	}
+}
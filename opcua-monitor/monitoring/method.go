@@ -0,0 +1,240 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// Method is an OPC UA method the monitor is allowed to call.
+type Method struct {
+	// Name the method is called by through the monitor.
+	Name string
+	// ObjectID is the NodeID of the object the method belongs to.
+	ObjectID string
+	// MethodID is the NodeID of the method.
+	MethodID string
+}
+
+// LoadMethodsFromFile reads the list of the callable methods from the JSON file, e.g.:
+//
+//	[
+//		{"name": "StartCIP", "objectId": "ns=3;s=Skid", "methodId": "ns=3;s=Skid.StartCIP"}
+//	]
+func LoadMethodsFromFile(filePath string) ([]Method, error) {
+	bytes, err := ioutil.ReadFile(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []Method
+	err = json.Unmarshal(bytes, &methods)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	for i, method := range methods {
+		if method.Name == "" || method.ObjectID == "" || method.MethodID == "" {
+			return nil, fmt.Errorf("%s: method #%d: the name, objectId and methodId are required", filePath, i+1)
+		}
+	}
+
+	return methods, nil
+}
+
+// HasMethod returns true if the method is allowed to be called on the server.
+func (monitor *OpcuaMonitor) HasMethod(name string) bool {
+	_, ok := monitor.methodByName(name)
+
+	return ok
+}
+
+// CallMethod calls the method of the allow-list on the server. The arguments
+// are converted to the data types declared by the InputArguments property
+// of the method. The output arguments are returned in the result.
+func (monitor *OpcuaMonitor) CallMethod(command data.CallCommand) data.CommandResult {
+	method, ok := monitor.methodByName(command.Method)
+
+	if !ok {
+		return monitor.rejectCall(fmt.Errorf("The method '%s' is not allowed to be called", command.Method))
+	}
+
+	// The server is requested without the lock, so the notifications aren't held up by the call.
+	monitor.synchronizer.Lock()
+	connected := monitor.connected
+	connection := monitor.connection
+	namespaces := monitor.namespaces
+	monitor.synchronizer.Unlock()
+
+	if !connected {
+		return monitor.rejectCall(fmt.Errorf("The server is not connected"))
+	}
+
+	objectID, err := resolveNodeID(method.ObjectID, namespaces)
+
+	if err != nil {
+		return monitor.rejectCall(err)
+	}
+
+	methodID, err := resolveNodeID(method.MethodID, namespaces)
+
+	if err != nil {
+		return monitor.rejectCall(err)
+	}
+
+	arguments, err := inputArguments(connection, methodID)
+
+	if err != nil {
+		return monitor.rejectCall(err)
+	}
+
+	if len(arguments) != len(command.Arguments) {
+		return monitor.rejectCall(fmt.Errorf("The method '%s' takes %d arguments, %d given",
+			method.Name, len(arguments), len(command.Arguments)))
+	}
+
+	inputs := make([]*ua.Variant, len(arguments))
+
+	for i, argument := range arguments {
+		inputs[i], err = variantFromValue(command.Arguments[i], argument.DataType)
+
+		if err != nil {
+			return monitor.rejectCall(fmt.Errorf("Argument '%s': %s", argument.Name, err))
+		}
+	}
+
+	res, err := connection.Call(&ua.CallMethodRequest{
+		ObjectID:       objectID,
+		MethodID:       methodID,
+		InputArguments: inputs,
+	})
+
+	if err != nil {
+		return monitor.rejectCall(err)
+	}
+
+	result := data.CommandResult{
+		StatusCode: uint32(res.StatusCode),
+		Status:     statusName(res.StatusCode),
+	}
+
+	for _, output := range res.OutputArguments {
+		value, err := valueFromVariant(output)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't convert the output of the method '%s': %s", method.Name, err)
+		}
+
+		result.Outputs = append(result.Outputs, value)
+	}
+
+	monitor.logger.Printf("User '%s' called the method '%s': %s", command.User, method.Name, result.Status)
+
+	return result
+}
+
+// inputArguments reads the InputArguments property of the method.
+// The method takes no arguments if it doesn't have the property.
+func inputArguments(connection *opcua.Client, methodID *ua.NodeID) ([]*ua.Argument, error) {
+	res, err := connection.Browse(&ua.BrowseRequest{
+		View: &ua.ViewDescription{
+			ViewID:    ua.NewTwoByteNodeID(0),
+			Timestamp: time.Now(),
+		},
+		RequestedMaxReferencesPerNode: maxReferencesPerNode,
+		NodesToBrowse: []*ua.BrowseDescription{
+			{
+				NodeID:          methodID,
+				BrowseDirection: ua.BrowseDirectionForward,
+				ReferenceTypeID: ua.NewNumericNodeID(0, id.HasProperty),
+				IncludeSubtypes: true,
+				NodeClassMask:   uint32(ua.NodeClassVariable),
+				ResultMask:      uint32(ua.BrowseResultMaskAll),
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Results) == 0 || res.Results[0].StatusCode != ua.StatusOK {
+		return nil, fmt.Errorf("Couldn't browse the method %s", methodID)
+	}
+
+	for _, ref := range res.Results[0].References {
+		if ref.BrowseName == nil || ref.BrowseName.Name != "InputArguments" || ref.NodeID == nil {
+			continue
+		}
+
+		return readArguments(connection, ref.NodeID.NodeID)
+	}
+
+	return nil, nil
+}
+
+// readArguments reads the list of the arguments from the property.
+func readArguments(connection *opcua.Client, property *ua.NodeID) ([]*ua.Argument, error) {
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{NodeID: property, AttributeID: ua.AttributeIDValue},
+		},
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Results) == 0 || res.Results[0].Status != ua.StatusOK || res.Results[0].Value == nil {
+		return nil, fmt.Errorf("Couldn't read the input arguments of the method")
+	}
+
+	objects, ok := res.Results[0].Value.Value().([]*ua.ExtensionObject)
+
+	if !ok {
+		return nil, fmt.Errorf("The input arguments have an invalid type")
+	}
+
+	arguments := make([]*ua.Argument, len(objects))
+
+	for i, object := range objects {
+		arguments[i], ok = object.Value.(*ua.Argument)
+
+		if !ok {
+			return nil, fmt.Errorf("The input argument #%d has an invalid type", i+1)
+		}
+	}
+
+	return arguments, nil
+}
+
+// rejectCall logs the reason the call has been rejected and returns it as the result.
+func (monitor *OpcuaMonitor) rejectCall(err error) data.CommandResult {
+	monitor.logger.Println("Couldn't call the method:", err)
+
+	return data.CommandResult{
+		StatusCode: uint32(ua.StatusBad),
+		Status:     statusName(ua.StatusBad),
+		Error:      err.Error(),
+	}
+}
+
+// methodByName returns the method of the allow-list with the name.
+func (monitor *OpcuaMonitor) methodByName(name string) (Method, bool) {
+	for _, method := range monitor.config.Methods {
+		if method.Name == name {
+			return method, true
+		}
+	}
+
+	return Method{}, false
+}
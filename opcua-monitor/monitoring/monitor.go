@@ -6,128 +6,291 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/gopcua/opcua/ua"
 
 	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
 )
 
-var (
-	synchronizer *sync.Mutex
-)
-
-func init() {
-	synchronizer = new(sync.Mutex)
+// ConnectionConfig describes how the monitor connects to the OPC UA server.
+type ConnectionConfig struct {
+	// Name identifies the server in the measurements.
+	Name     string
+	Endpoint string
+	// BackupEndpoints are the endpoints of the redundant servers
+	// the monitor fails over to in order of preference.
+	BackupEndpoints []string
+	// MinServiceLevel is the ServiceLevel below which the server is considered
+	// degraded and the monitor fails over to a healthy backup server.
+	// It's ignored without the backup servers.
+	MinServiceLevel int
+	// Mode is the acquisition mode: subscription (default) or polling.
+	Mode       string
+	Security   SecurityConfig
+	Identity   IdentityConfig
+	Parameters ParameterSource
+	// EventNotifier is the NodeID of the node the alarms and events
+	// are received from. The events aren't monitored if it's empty.
+	EventNotifier string
+	// Methods are the methods of the server allowed to be called.
+	Methods []Method
+	// HistoryAccess means the server keeps the history of the parameters,
+	// so the gaps in the stored data can be filled from it.
+	HistoryAccess bool
 }
 
 // OpcuaMonitor is a class for interaction with OPC UA server.
 // You just need to connect to the server and then subscribe to certain parameters.
 type OpcuaMonitor struct {
-	endpoint      string
-	connection    *opcua.Client
-	subscription  *opcua.Subscription
-	ctx           context.Context
-	logger        *log.Logger
-	interval      time.Duration
-	parameters    map[uint32]string
-	handleCounter uint32
-	fanout        *shared.Fanout
-	stop          chan interface{}
-	stopped       bool
-}
-
-// Connect establishes the connection between the server and the monitor.
+	config           ConnectionConfig
+	endpoint         string
+	connection       *opcua.Client
+	subscription     *opcua.Subscription
+	ctx              context.Context
+	logger           *log.Logger
+	interval         time.Duration
+	parameters       map[uint32]Parameter
+	loaded           map[string]Parameter
+	items            map[uint32]uint32
+	namespaces       []string
+	nodesPerRead     int
+	itemsPerCall     int
+	creating         map[uint32]bool
+	freshness        map[uint32]*freshness
+	described        map[uint32]bool
+	metadataStore    shared.MetadataStore
+	diagnosticsStore shared.DiagnosticsStore
+	recorder         *Recorder
+	notifications    int
+	keepAlives       int
+	missedMessages   int
+	sequenceNumber   uint32
+	diagnosed        time.Time
+	handleCounter    uint32
+	fanout           *shared.Fanout
+	historyFanout    *shared.Fanout
+	historyStore     shared.HistoryStore
+	stop             chan interface{}
+	stopped          bool
+	connected        bool
+	degraded         bool
+	failoverProbed   time.Time
+	probing          bool
+	probes           chan failoverProbe
+	eventHandle      uint32
+	eventsMonitored  bool
+	browsePending    bool
+	reloads          chan<- interface{}
+	synchronizer     *sync.Mutex
+}
+
+// Connect establishes the connection between the server and the monitor
+// and restores monitoring of the parameters known to the monitor.
 func (monitor *OpcuaMonitor) Connect() error {
-	opts := []opcua.Option{
-		opcua.SecurityModeString("None"),
-		opcua.AuthAnonymous(),
+	// Discovering and dialing the servers takes a while,
+	// so the monitor is locked only to swap the connection in.
+	connection, address, err := monitor.dialBest()
+
+	if err != nil {
+		return err
 	}
 
-	monitor.connection = opcua.NewClient(monitor.endpoint, opts...)
-	err := monitor.connection.Connect(monitor.ctx)
+	// The server may have reordered the namespaces since the last connection.
+	namespaces, err := readNamespaces(connection)
 	monitor.handleConnectionError(err)
 
 	if err != nil {
+		connection.Close()
 		return err
 	}
 
-	monitor.subscription, err = monitor.connection.Subscribe(&opcua.SubscriptionParameters{
-		Interval: monitor.interval,
-	})
+	// The parameters are read in chunks the server accepts in the polling mode.
+	nodesPerRead := readOperationLimit(connection, id.Server_ServerCapabilities_OperationLimits_MaxNodesPerRead,
+		maxNodesPerRead)
+
+	// The parameters are read without the subscription in the polling mode.
+	var subscription *opcua.Subscription
+	itemsPerCall := defaultMaxMonitoredItemsPerCall
+
+	if !monitor.config.polling() {
+		itemsPerCall = readOperationLimit(connection, id.Server_ServerCapabilities_OperationLimits_MaxMonitoredItemsPerCall,
+			defaultMaxMonitoredItemsPerCall)
+
+		subscription, err = connection.Subscribe(&opcua.SubscriptionParameters{
+			Interval:          monitor.interval,
+			MaxKeepAliveCount: keepAliveCount(monitor.interval),
+		})
+		monitor.handleConnectionError(err)
+
+		if err != nil {
+			connection.Close()
+			return err
+		}
+	}
+
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.connection = connection
+	monitor.subscription = subscription
+	monitor.endpoint = address
+	monitor.namespaces = namespaces
+	monitor.nodesPerRead = nodesPerRead
+	monitor.itemsPerCall = itemsPerCall
+	monitor.degraded = false
+	monitor.sequenceNumber = 0
+	monitor.resetDiagnostics()
+
+	// The monitored items of the previous subscription are gone along with it.
+	monitor.items = make(map[uint32]uint32)
+	monitor.creating = make(map[uint32]bool)
+	monitor.connected = true
+	monitor.restoreParameters()
+
+	return nil
+}
+
+// dial establishes the connection with the server at the address.
+func (monitor *OpcuaMonitor) dial(address string) (*opcua.Client, error) {
+	endpoints, err := DiscoverEndpoints(address)
 	monitor.handleConnectionError(err)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	for _, endpoint := range endpoints {
+		monitor.logger.Printf("The server '%s' offers the endpoint %s", monitor.config.Name, FormatEndpoint(endpoint))
+	}
+
+	// Choose the most secure endpoint the monitor is able to connect to.
+	endpoint, err := monitor.config.SelectEndpoint(endpoints)
+	monitor.handleSecurityError(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.logger.Printf("Selected the endpoint %s", FormatEndpoint(endpoint))
+
+	identity := monitor.config.Identity
+	opts, err := monitor.config.Security.options(endpoint, identity.tokenType())
+	monitor.handleSecurityError(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := identity.option()
+	monitor.handleIdentityError(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, auth, opcua.RequestTimeout(requestTimeout(monitor.interval)))
+
+	connection := opcua.NewClient(address, opts...)
+	err = connection.Connect(monitor.ctx)
+	monitor.handleConnectionError(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return connection, nil
 }
 
 // CloseConnection closes the connection and stops
 // receiving parameter updates.
 func (monitor *OpcuaMonitor) CloseConnection() {
-	monitor.connection.Close()
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.closeConnection()
 }
 
 // MonitorParameter makes the monitor receive updates
 // of the specified parameter from the server.
-func (monitor *OpcuaMonitor) MonitorParameter(nodeID string) error {
-	// If not subscribed yet.
-	if monitor.subscription == nil {
-		subscription, err := monitor.connection.Subscribe(&opcua.SubscriptionParameters{
-			Interval: monitor.interval,
-		})
-		monitor.handleConnectionError(err)
+func (monitor *OpcuaMonitor) MonitorParameter(parameter Parameter) error {
+	return monitor.monitorParameters([]Parameter{parameter})[0].Err
+}
+
+// UnmonitorParameter stops monitoring the parameter with the given name, so it's
+// neither restored after reconnecting nor read in the polling mode any more.
+func (monitor *OpcuaMonitor) UnmonitorParameter(name string) error {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	handle, ok := monitor.handleByName(name)
+
+	if !ok {
+		return fmt.Errorf("The parameter '%s' is not monitored", name)
+	}
+
+	// The monitored items are deleted along with the subscription when the connection is lost.
+	if id, ok := monitor.items[handle]; ok && monitor.connected {
+		err := monitor.unmonitorItem(id)
 
 		if err != nil {
 			return err
 		}
-
-		monitor.subscription = subscription
 	}
 
-	// Parse NodeID.
-	id, err := ua.ParseNodeID(nodeID)
+	delete(monitor.parameters, handle)
+	delete(monitor.items, handle)
+	delete(monitor.freshness, handle)
+	delete(monitor.described, handle)
+
+	return nil
+}
+
+// unmonitorItem deletes the monitored item with the given server ID.
+func (monitor *OpcuaMonitor) unmonitorItem(id uint32) error {
+	res, err := monitor.subscription.Unmonitor(id)
 	monitor.handleSubscriptionError(err)
 
 	if err != nil {
 		return err
 	}
 
-	synchronizer.Lock()
-	defer synchronizer.Unlock()
-
-	// Get the parameter name.
-	tokens := strings.Split(nodeID, ";")
-	tokens = strings.Split(tokens[1], "=")
-	parameter := tokens[1]
-
-	// Subscribe to the parameter.
-	request := opcua.NewMonitoredItemCreateRequestWithDefaults(id,
-		ua.AttributeIDValue, monitor.handleCounter)
-	res, err := monitor.subscription.Monitor(ua.TimestampsToReturnBoth, request)
-	monitor.handleSubscriptionError(err)
+	if len(res.Results) == 0 {
+		err = fmt.Errorf("The server returned no result")
+		monitor.handleSubscriptionError(err)
 
-	if err != nil {
 		return err
 	}
 
-	if status := res.Results[0].StatusCode; status != ua.StatusOK {
+	// The item is already gone if the server doesn't know it.
+	if status := res.Results[0]; status != ua.StatusOK && status != ua.StatusBadMonitoredItemIDInvalid {
 		err = fmt.Errorf("Bad response status")
 		monitor.handleSubscriptionError(err)
 
 		return err
 	}
 
-	monitor.parameters[monitor.handleCounter] = parameter
-	monitor.handleCounter++
-
 	return nil
 }
 
+// handleByName returns the client handle of the parameter with the given name.
+func (monitor *OpcuaMonitor) handleByName(name string) (uint32, bool) {
+	for handle, parameter := range monitor.parameters {
+		if parameter.Name == name {
+			return handle, true
+		}
+	}
+
+	return 0, false
+}
+
+// Name returns the name of the server the monitor is connected to.
+func (monitor *OpcuaMonitor) Name() string {
+	return monitor.config.Name
+}
+
 // AddSubscriber adds a new subscriber for him to receive parameters.
 func (monitor *OpcuaMonitor) AddSubscriber(channel chan<- data.Measurement) {
 	monitor.fanout.AddChannel(channel)
@@ -148,55 +311,258 @@ func (monitor *OpcuaMonitor) Start() {
 		return
 	}
 
-	go monitor.subscription.Run(monitor.ctx)
+	go monitor.run()
 
-	go func() {
-		for {
-			select {
-			case <-monitor.ctx.Done():
-				monitor.logger.Println("Disconnected from the server.")
-				return
+	monitor.stopped = false
+}
+
+// Stop stops the update receiving loop.
+func (monitor *OpcuaMonitor) Stop() {
+	monitor.stop <- true
+	monitor.stopped = true
+}
+
+// run receives parameter updates from the server and restores
+// the connection each time it's lost until the monitor is stopped.
+func (monitor *OpcuaMonitor) run() {
+	delay := minReconnectDelay
+
+	for {
+		monitor.synchronizer.Lock()
+		connected := monitor.connected
+		previous := monitor.endpoint
+		monitor.synchronizer.Unlock()
 
-			case <-monitor.stop:
-				monitor.logger.Println("Monitor stopped")
+		if !connected {
+			if !monitor.reconnect(delay) {
 				return
+			}
 
-			case message := <-monitor.subscription.Notifs:
-				switch mes := message.Value.(type) {
-				case *ua.DataChangeNotification:
-					monitor.sendParametersToFanout(mes)
+			monitor.synchronizer.Lock()
+			current := monitor.endpoint
+			monitor.synchronizer.Unlock()
 
-				default:
-					monitor.logger.Println("Unknown message type")
-				}
+			if current != previous {
+				monitor.logger.Printf("Failed over the server %s from %s to %s", monitor.config.Name, previous, current)
+				monitor.sendFailoverEvent(previous)
+			} else {
+				monitor.logger.Println("Restored the connection to the server", monitor.config.Name)
+				monitor.sendConnectionEvent(data.ConnectionRestored, nil)
 			}
 		}
-	}()
 
-	monitor.stopped = false
+		// Fill the gap the monitor hasn't been receiving the parameters for.
+		monitor.startBackfill()
+
+		// Describe the parameters added while the server was unavailable.
+		monitor.startDescribing()
+
+		// Browse the parameters the server was unavailable to browse at startup.
+		monitor.requestDeferredBrowse()
+
+		err := monitor.receive()
+
+		if err == nil {
+			return
+		}
+
+		// Switch to the healthy server at once, the subscription is recreated there.
+		delay = minReconnectDelay
+
+		if _, ok := err.(*failoverError); ok {
+			delay = 0
+		}
+
+		monitor.logger.Printf("Lost the connection to the server %s: %s", monitor.config.Name, err)
+		monitor.sendConnectionEvent(data.ConnectionLost, err)
+
+		monitor.synchronizer.Lock()
+		monitor.closeConnection()
+		monitor.synchronizer.Unlock()
+	}
 }
 
-// Stop stops the update receiving loop.
-func (monitor *OpcuaMonitor) Stop() {
-	monitor.stop <- true
-	monitor.stopped = true
+// receive runs the publishing loop of the subscription (or polls the parameters
+// in the polling mode) and sends notifications to the fanout. It returns nil if the monitor has been stopped and the cause
+// of the failure if the publishing loop has terminated.
+func (monitor *OpcuaMonitor) receive() error {
+	if monitor.config.polling() {
+		return monitor.poll()
+	}
+
+	ctx, cancel := context.WithCancel(monitor.ctx)
+	defer cancel()
+
+	monitor.synchronizer.Lock()
+	connection := monitor.connection
+	subscription := monitor.subscription
+	monitor.synchronizer.Unlock()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- monitor.publish(ctx, connection, subscription)
+	}()
+
+	// Retry creating the monitored items rejected by the server.
+	retry := time.NewTicker(itemRetryInterval)
+	defer retry.Stop()
+
+	staleCheck := time.NewTicker(staleCheckInterval)
+	defer staleCheck.Stop()
+
+	healthCheck := time.NewTicker(healthCheckInterval)
+	defer healthCheck.Stop()
+
+	diagnose := time.NewTicker(diagnosticsInterval)
+	defer diagnose.Stop()
+
+	var lastErr error
+
+	for {
+		select {
+		case <-retry.C:
+			monitor.retryItems()
+
+		case <-staleCheck.C:
+			monitor.checkStaleness()
+
+		case <-healthCheck.C:
+			err := monitor.checkHealth()
+
+			if err != nil {
+				return err
+			}
+
+		case probe := <-monitor.probes:
+			err := monitor.checkProbe(probe)
+
+			if err != nil {
+				return err
+			}
+
+		case <-diagnose.C:
+			monitor.sendDiagnostics()
+
+		case <-monitor.ctx.Done():
+			monitor.logger.Println("Disconnected from the server.")
+			return nil
+
+		case <-monitor.stop:
+			monitor.logger.Println("Monitor stopped")
+			return nil
+
+		case err := <-done:
+			if err == nil {
+				err = lastErr
+			}
+
+			if err == nil {
+				err = fmt.Errorf("The publishing loop has terminated")
+			}
+
+			return err
+
+		case message := <-subscription.Notifs:
+			if message.Error != nil {
+				monitor.logger.Println("Received an error from the server:", message.Error)
+				lastErr = message.Error
+
+				continue
+			}
+
+			switch mes := message.Value.(type) {
+			case *ua.DataChangeNotification:
+				monitor.countNotifications(len(mes.MonitoredItems))
+				monitor.sendParametersToFanout(mes)
+
+			case *ua.EventNotificationList:
+				monitor.countNotifications(len(mes.Events))
+				monitor.sendEventsToFanout(mes)
+
+			case *ua.StatusChangeNotification:
+				// The server has closed or lost the subscription, so it's recreated after reconnecting.
+				return fmt.Errorf("The subscription has changed the status to %s", statusName(mes.Status))
+
+			default:
+				monitor.logger.Println("Unknown message type")
+			}
+		}
+	}
 }
 
 func (monitor *OpcuaMonitor) sendParametersToFanout(message *ua.DataChangeNotification) {
+	monitor.fanout.SendMeasurement(monitor.parametersState(message))
+}
+
+// parametersState converts the notification to the state of the parameters
+// and writes it to the recorder if the recording is enabled.
+func (monitor *OpcuaMonitor) parametersState(message *ua.DataChangeNotification) data.ParametersState {
 	measure := data.ParametersState{
-		Timestamp:  time.Now(),
-		Parameters: make(map[string]float64),
+		Server:     monitor.config.Name,
+		Parameters: make(map[string]data.Sample),
+	}
+
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	// The server may still send the values of the parameters which have been unmonitored.
+	items := make([]*ua.MonitoredItemNotification, 0, len(message.MonitoredItems))
+
+	for _, item := range message.MonitoredItems {
+		if item == nil {
+			continue
+		}
+
+		if _, ok := monitor.parameters[item.ClientHandle]; !ok {
+			monitor.logger.Printf("Received the value of the unknown monitored item %d", item.ClientHandle)
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	if len(items) != len(message.MonitoredItems) {
+		message = &ua.DataChangeNotification{MonitoredItems: items, DiagnosticInfos: message.DiagnosticInfos}
+	}
+
+	if monitor.recorder != nil && len(message.MonitoredItems) > 0 {
+		err := monitor.recorder.record(monitor.parameters, message)
+
+		if err != nil {
+			monitor.logger.Println("Couldn't record the notification:", err)
+		}
 	}
 
 	// Get the values of the monitored parameters.
 	for _, item := range message.MonitoredItems {
 		parameter := monitor.parameters[item.ClientHandle]
-		value := item.Value.Value.Value().(float64)
 
-		measure.Parameters[parameter] = value
+		if item.Value == nil {
+			continue
+		}
+
+		sample, err := sampleFromDataValue(item.Value)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't read the value of the parameter '%s': %s", parameter.Name, err)
+			continue
+		}
+
+		measure.Parameters[parameter.Name] = sample
+		monitor.markUpdated(item.ClientHandle, sample)
+
+		// The state is stamped with the latest device time.
+		if sample.SourceTimestamp.After(measure.Timestamp) {
+			measure.Timestamp = sample.SourceTimestamp
+		}
+	}
+
+	if measure.Timestamp.IsZero() {
+		measure.Timestamp = time.Now()
 	}
 
-	monitor.fanout.SendMeasurement(measure)
+	return measure
 }
 
 func (monitor *OpcuaMonitor) handleConnectionError(err error) {
@@ -205,6 +571,18 @@ func (monitor *OpcuaMonitor) handleConnectionError(err error) {
 	}
 }
 
+func (monitor *OpcuaMonitor) handleSecurityError(err error) {
+	if err != nil {
+		monitor.logger.Println("Couldn't set up the secure channel:", err)
+	}
+}
+
+func (monitor *OpcuaMonitor) handleIdentityError(err error) {
+	if err != nil {
+		monitor.logger.Println("Couldn't load the user credentials:", err)
+	}
+}
+
 func (monitor *OpcuaMonitor) handleSubscriptionError(err error) {
 	if err != nil {
 		monitor.logger.Println("Couldn't subscribe to the parameter:", err)
@@ -218,16 +596,25 @@ func (monitor *OpcuaMonitor) handleRemoveSubscriptionError(err error) {
 }
 
 // NewOpcuaMonitor creates a new monitor to track data changes on the OPC UA server and translate them to subscribers.
-func NewOpcuaMonitor(ctx context.Context, endpoint string, logger *log.Logger, interval time.Duration) *OpcuaMonitor {
+func NewOpcuaMonitor(ctx context.Context, config ConnectionConfig, logger *log.Logger, interval time.Duration) *OpcuaMonitor {
 	return &OpcuaMonitor{
-		endpoint:      endpoint,
+		config:        config,
+		endpoint:      config.Endpoint,
 		ctx:           ctx,
 		logger:        logger,
 		interval:      interval,
-		parameters:    make(map[uint32]string),
+		parameters:    make(map[uint32]Parameter),
+		loaded:        make(map[string]Parameter),
+		items:         make(map[uint32]uint32),
+		creating:      make(map[uint32]bool),
+		freshness:     make(map[uint32]*freshness),
+		described:     make(map[uint32]bool),
 		fanout:        shared.NewFanout(),
+		historyFanout: shared.NewFanout(),
 		handleCounter: 0,
 		stop:          make(chan interface{}),
+		probes:        make(chan failoverProbe, 1),
 		stopped:       true,
+		synchronizer:  new(sync.Mutex),
 	}
 }
@@ -0,0 +1,37 @@
+package monitoring
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestParametersStateUnknownHandle(t *testing.T) {
+	config := DefaultConnectionConfig()
+	config.Name = "server"
+	monitor := NewOpcuaMonitor(context.Background(), config, log.New(ioutil.Discard, "", 0), 0)
+	monitor.parameters[1] = Parameter{Name: "Temperature", NodeID: "ns=2;s=Temperature"}
+
+	value := func(number float64) *ua.DataValue {
+		return &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(number)}
+	}
+
+	// The item 2 has been unmonitored, but the server still sends its value.
+	state := monitor.parametersState(&ua.DataChangeNotification{
+		MonitoredItems: []*ua.MonitoredItemNotification{
+			{ClientHandle: 1, Value: value(36.6)},
+			{ClientHandle: 2, Value: value(1)},
+		},
+	})
+
+	if len(state.Parameters) != 1 || state.Parameters["Temperature"].Number != 36.6 {
+		t.Errorf("parametersState() = %+v, want only Temperature 36.6", state.Parameters)
+	}
+
+	if _, ok := state.Parameters[""]; ok {
+		t.Error("The value of the unknown item is sent without the name")
+	}
+}
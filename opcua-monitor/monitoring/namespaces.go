@@ -0,0 +1,120 @@
+package monitoring
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// namespaceURIPattern matches the NodeID with the namespace URI
+// instead of the namespace index, e.g. 'nsu=urn:plc:project;s=Temperature'.
+var namespaceURIPattern = regexp.MustCompile(`^nsu=(.+?);([isgb]=.*)$`)
+
+// identifierPattern matches the NodeID without the namespace, e.g. 'i=2253'.
+var identifierPattern = regexp.MustCompile(`^[isgb]=`)
+
+// parseNodeID checks the syntax of the NodeID. The NodeID with the namespace URI
+// is parsed as if it belonged to the namespace 0 since the index of the namespace
+// isn't known before connecting to the server. See resolveNodeID.
+func parseNodeID(nodeID string) (*ua.NodeID, error) {
+	if matches := namespaceURIPattern.FindStringSubmatch(nodeID); matches != nil {
+		return parseNamespaceNodeID(0, matches[2])
+	}
+
+	return parseIndexedNodeID(nodeID)
+}
+
+// resolveNodeID parses the NodeID replacing its namespace URI
+// with the index of the namespace in the namespace array of the server.
+func resolveNodeID(nodeID string, namespaces []string) (*ua.NodeID, error) {
+	matches := namespaceURIPattern.FindStringSubmatch(nodeID)
+
+	if matches == nil {
+		return parseIndexedNodeID(nodeID)
+	}
+
+	for index, uri := range namespaces {
+		if uri == matches[1] {
+			return parseNamespaceNodeID(index, matches[2])
+		}
+	}
+
+	return nil, fmt.Errorf("The namespace '%s' is unknown to the server", matches[1])
+}
+
+// formatNodeID returns the string form of the NodeID with the namespace URI instead
+// of the namespace index, e.g. 'nsu=urn:plc:project;s=Temperature'. The NodeIDs
+// of the namespace 0 and of the namespaces missing in the array keep the index.
+func formatNodeID(id *ua.NodeID, namespaces []string) string {
+	index := int(id.Namespace())
+
+	if index == 0 || index >= len(namespaces) {
+		return id.String()
+	}
+
+	identifier := id.String()
+
+	// Strip the namespace index, e.g. 'ns=2;s=Temperature'.
+	if separator := strings.Index(identifier, ";"); strings.HasPrefix(identifier, "ns=") && separator >= 0 {
+		identifier = identifier[separator+1:]
+	}
+
+	return fmt.Sprintf("nsu=%s;%s", namespaces[index], identifier)
+}
+
+// parseIndexedNodeID parses the NodeID with the namespace index.
+// The NodeID without the index belongs to the namespace 0, e.g. 'i=2253'.
+func parseIndexedNodeID(nodeID string) (*ua.NodeID, error) {
+	if identifierPattern.MatchString(nodeID) {
+		return parseNamespaceNodeID(0, nodeID)
+	}
+
+	return ua.ParseNodeID(nodeID)
+}
+
+// parseNamespaceNodeID parses the identifier of the node of the namespace with the given index.
+func parseNamespaceNodeID(index int, identifier string) (*ua.NodeID, error) {
+	return ua.ParseNodeID(fmt.Sprintf("ns=%d;%s", index, identifier))
+}
+
+// readNamespaces reads the namespace array of the server
+// the namespace URIs of the NodeIDs are resolved against.
+func readNamespaces(connection *opcua.Client) ([]string, error) {
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{
+				NodeID:       ua.NewNumericNodeID(0, id.Server_NamespaceArray),
+				AttributeID:  ua.AttributeIDValue,
+				DataEncoding: &ua.QualifiedName{},
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Results) == 0 {
+		return nil, fmt.Errorf("The server returned no namespace array")
+	}
+
+	if status := res.Results[0].Status; status != ua.StatusOK {
+		return nil, fmt.Errorf("Couldn't read the namespace array: %s", statusName(status))
+	}
+
+	if res.Results[0].Value == nil {
+		return nil, fmt.Errorf("The server returned no namespace array")
+	}
+
+	namespaces, ok := res.Results[0].Value.Value().([]string)
+
+	if !ok {
+		return nil, fmt.Errorf("The namespace array is not a list of strings")
+	}
+
+	return namespaces, nil
+}
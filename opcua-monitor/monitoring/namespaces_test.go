@@ -0,0 +1,114 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestFormatNodeID(t *testing.T) {
+	namespaces := []string{"http://opcfoundation.org/UA/", "urn:server", "urn:plc:project"}
+
+	tests := []struct {
+		id   *ua.NodeID
+		want string
+	}{
+		{ua.NewNumericNodeID(0, 2253), "i=2253"},
+		{ua.NewStringNodeID(2, "Temperature"), "nsu=urn:plc:project;s=Temperature"},
+		{ua.NewNumericNodeID(1, 42), "nsu=urn:server;i=42"},
+		{ua.NewStringNodeID(5, "Pressure"), "ns=5;s=Pressure"},
+	}
+
+	for _, test := range tests {
+		got := formatNodeID(test.id, namespaces)
+
+		if got != test.want {
+			t.Errorf("formatNodeID(%s) = %s, want %s", test.id, got, test.want)
+		}
+
+		// The formatted NodeID is resolved back to the same node.
+		id, err := resolveNodeID(got, namespaces)
+
+		if err != nil {
+			t.Errorf("resolveNodeID(%s) failed: %s", got, err)
+			continue
+		}
+
+		if id.String() != test.id.String() {
+			t.Errorf("resolveNodeID(%s) = %s, want %s", got, id, test.id)
+		}
+	}
+}
+
+func TestJoinBrowsePath(t *testing.T) {
+	tests := []struct {
+		parent string
+		name   string
+		want   string
+	}{
+		{"", "Temperature", "Temperature"},
+		{"Tank1", "Temperature", "Tank1.Temperature"},
+		{"Line.Tank1", "Level", "Line.Tank1.Level"},
+	}
+
+	for _, test := range tests {
+		if got := joinBrowsePath(test.parent, test.name); got != test.want {
+			t.Errorf("joinBrowsePath(%q, %q) = %q, want %q", test.parent, test.name, got, test.want)
+		}
+	}
+}
+
+func TestResolveNodeID(t *testing.T) {
+	namespaces := []string{"http://opcfoundation.org/UA/", "urn:server", "urn:plc:project"}
+
+	tests := []struct {
+		nodeID string
+		want   string
+		fails  bool
+	}{
+		{nodeID: "i=2253", want: "i=2253"},
+		{nodeID: "ns=3;s=Temperature", want: "ns=3;s=Temperature"},
+		{nodeID: "nsu=urn:plc:project;s=Temperature", want: "ns=2;s=Temperature"},
+		{nodeID: "nsu=urn:server;i=1001", want: "ns=1;i=1001"},
+		{nodeID: "nsu=http://opcfoundation.org/UA/;i=2253", want: "i=2253"},
+		{nodeID: "nsu=urn:unknown;s=Temperature", fails: true},
+		{nodeID: "nsu=urn:plc:project;x=Temperature", fails: true},
+		{nodeID: "ns=abc;s=Temperature", fails: true},
+	}
+
+	for _, test := range tests {
+		id, err := resolveNodeID(test.nodeID, namespaces)
+
+		if test.fails {
+			if err == nil {
+				t.Errorf("resolveNodeID(%s) = %s, want an error", test.nodeID, id)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("resolveNodeID(%s) failed: %s", test.nodeID, err)
+			continue
+		}
+
+		if id.String() != test.want {
+			t.Errorf("resolveNodeID(%s) = %s, want %s", test.nodeID, id, test.want)
+		}
+	}
+}
+
+func TestParseNodeID(t *testing.T) {
+	// The namespace URI isn't resolved before connecting, but the syntax is checked.
+	for _, nodeID := range []string{"i=2253", "ns=3;s=Temperature", "nsu=urn:plc:project;s=Temperature"} {
+		if _, err := parseNodeID(nodeID); err != nil {
+			t.Errorf("parseNodeID(%s) failed: %s", nodeID, err)
+		}
+	}
+
+	for _, nodeID := range []string{"Temperature", "nsu=urn:plc:project"} {
+		if _, err := parseNodeID(nodeID); err == nil {
+			t.Errorf("parseNodeID(%s) succeeded, want an error", nodeID)
+		}
+	}
+}
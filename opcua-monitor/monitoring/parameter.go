@@ -0,0 +1,134 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// Parameter is an OPC UA variable the monitor receives updates for.
+type Parameter struct {
+	Name     string
+	NodeID   string
+	Sampling SamplingConfig
+	// Writable allows writing the parameter value through the monitor.
+	Writable bool
+	// Limits are the bounds the written numeric values must be within.
+	// Any value is accepted if the limits are nil.
+	Limits *data.Bounds
+	// Bounds are the initial alerting bounds of the parameter.
+	Bounds   *data.Bounds
+	Metadata data.ParameterMetadata
+	// MaxAge is the time after the last update the parameter becomes stale in.
+	// The parameter never becomes stale if it's zero.
+	MaxAge time.Duration
+	// Probe makes the monitor read the parameter before marking it stale,
+	// so the parameter whose value is stable isn't considered stale.
+	Probe bool
+}
+
+// parseOption sets the parameter setting from the option. Besides
+// the sampling options, the supported options are:
+//
+//	writable
+//	limits=0:100
+//	maxage=30s
+//	probe
+func (parameter *Parameter) parseOption(option string) error {
+	tokens := strings.SplitN(option, "=", 2)
+
+	switch strings.ToLower(tokens[0]) {
+	case "writable":
+		if len(tokens) > 1 {
+			return fmt.Errorf("Invalid option '%s'", option)
+		}
+
+		parameter.Writable = true
+
+	case "limits":
+		if len(tokens) < 2 {
+			return fmt.Errorf("Invalid option '%s'", option)
+		}
+
+		bounds, err := parseBounds(tokens[1])
+
+		if err != nil {
+			return err
+		}
+
+		parameter.Limits = &bounds
+
+	case "maxage":
+		if len(tokens) < 2 {
+			return fmt.Errorf("Invalid option '%s'", option)
+		}
+
+		maxAge, err := time.ParseDuration(tokens[1])
+
+		if err != nil {
+			return err
+		}
+
+		if maxAge < 0 {
+			return fmt.Errorf("Invalid option '%s': the maximum age is negative", option)
+		}
+
+		parameter.MaxAge = maxAge
+
+	case "probe":
+		if len(tokens) > 1 {
+			return fmt.Errorf("Invalid option '%s'", option)
+		}
+
+		parameter.Probe = true
+
+	default:
+		return parameter.Sampling.parseOption(option)
+	}
+
+	return nil
+}
+
+// parseBounds parses the 'lower:upper' bounds.
+func parseBounds(value string) (data.Bounds, error) {
+	parts := strings.SplitN(value, ":", 2)
+
+	if len(parts) < 2 {
+		return data.Bounds{}, fmt.Errorf("Invalid bounds '%s'", value)
+	}
+
+	lower, err := strconv.ParseFloat(parts[0], 64)
+
+	if err != nil {
+		return data.Bounds{}, err
+	}
+
+	upper, err := strconv.ParseFloat(parts[1], 64)
+
+	if err != nil {
+		return data.Bounds{}, err
+	}
+
+	if upper < lower {
+		return data.Bounds{}, fmt.Errorf("Invalid bounds '%s': the lower bound exceeds the upper one", value)
+	}
+
+	return data.Bounds{LowerBound: lower, UpperBound: upper}, nil
+}
+
+// parameterNameFromNodeID derives the parameter name from the NodeID. The name of
+// the string NodeID is its identifier, e.g. 'ns=3;s=Temperature' gives 'Temperature'.
+// Other NodeIDs are used as the names as they are.
+func parameterNameFromNodeID(nodeID string) string {
+	id, err := parseNodeID(nodeID)
+
+	if err != nil || id.Type() != ua.NodeIDTypeString {
+		return nodeID
+	}
+
+	return id.StringID()
+}
@@ -0,0 +1,154 @@
+package monitoring
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// Acquisition modes of the monitor.
+const (
+	// ModeSubscription makes the server report the changes of the parameters.
+	ModeSubscription = "subscription"
+	// ModePolling makes the monitor read the parameters at the fixed interval.
+	// It's meant for the servers having broken subscriptions.
+	ModePolling = "polling"
+)
+
+// polling returns true if the parameters are read at the fixed interval
+// instead of being reported by the server.
+func (config ConnectionConfig) polling() bool {
+	return config.Mode == ModePolling
+}
+
+// poll reads all the parameters with as few requests as the server allows at the interval and
+// sends them to the fanout the same way as the subscription notifications.
+// It returns nil if the monitor has been stopped and the cause
+// of the failure if the parameters couldn't be read.
+func (monitor *OpcuaMonitor) poll() error {
+	ticker := time.NewTicker(monitor.interval)
+	defer ticker.Stop()
+
+	staleCheck := time.NewTicker(staleCheckInterval)
+	defer staleCheck.Stop()
+
+	healthCheck := time.NewTicker(healthCheckInterval)
+	defer healthCheck.Stop()
+
+	diagnose := time.NewTicker(diagnosticsInterval)
+	defer diagnose.Stop()
+
+	for {
+		select {
+		case <-monitor.ctx.Done():
+			monitor.logger.Println("Disconnected from the server.")
+			return nil
+
+		case <-monitor.stop:
+			monitor.logger.Println("Monitor stopped")
+			return nil
+
+		case <-staleCheck.C:
+			monitor.checkStaleness()
+
+		case <-healthCheck.C:
+			err := monitor.checkHealth()
+
+			if err != nil {
+				return err
+			}
+
+		case probe := <-monitor.probes:
+			err := monitor.checkProbe(probe)
+
+			if err != nil {
+				return err
+			}
+
+		case <-diagnose.C:
+			monitor.sendDiagnostics()
+
+		case <-ticker.C:
+			message, err := monitor.readParameters()
+
+			if err != nil {
+				return err
+			}
+
+			if len(message.MonitoredItems) > 0 {
+				monitor.countNotifications(len(message.MonitoredItems))
+				monitor.sendParametersToFanout(message)
+			}
+		}
+	}
+}
+
+// readParameters reads the values of all the parameters and returns them
+// in the form of the notification of the subscription.
+func (monitor *OpcuaMonitor) readParameters() (*ua.DataChangeNotification, error) {
+	monitor.synchronizer.Lock()
+	connection := monitor.connection
+	limit := monitor.nodesPerRead
+	handles := make([]uint32, 0, len(monitor.parameters))
+	nodes := make([]*ua.ReadValueID, 0, len(monitor.parameters))
+
+	for handle, parameter := range monitor.parameters {
+		id, err := resolveNodeID(parameter.NodeID, monitor.namespaces)
+
+		if err != nil {
+			continue
+		}
+
+		handles = append(handles, handle)
+		nodes = append(nodes, &ua.ReadValueID{
+			NodeID:       id,
+			AttributeID:  ua.AttributeIDValue,
+			DataEncoding: &ua.QualifiedName{},
+		})
+	}
+	monitor.synchronizer.Unlock()
+
+	message := &ua.DataChangeNotification{
+		MonitoredItems: make([]*ua.MonitoredItemNotification, 0, len(nodes)),
+	}
+
+	if len(nodes) == 0 {
+		return message, nil
+	}
+
+	if limit <= 0 {
+		limit = maxNodesPerRead
+	}
+
+	for start := 0; start < len(nodes); start += limit {
+		end := start + limit
+
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+
+		chunk := nodes[start:end]
+		res, err := connection.Read(&ua.ReadRequest{
+			NodesToRead:        chunk,
+			TimestampsToReturn: ua.TimestampsToReturnBoth,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(res.Results) != len(chunk) {
+			return nil, fmt.Errorf("The server returned %d results for %d parameters", len(res.Results), len(chunk))
+		}
+
+		for i, value := range res.Results {
+			message.MonitoredItems = append(message.MonitoredItems, &ua.MonitoredItemNotification{
+				ClientHandle: handles[start+i],
+				Value:        value,
+			})
+		}
+	}
+
+	return message, nil
+}
@@ -0,0 +1,155 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// keepAliveInterval is the interval the server sends the keep-alive messages at
+	// if none of the parameters has changed, so the lost subscription is noticed early.
+	keepAliveInterval = 5 * time.Second
+	// minRequestTimeout is the timeout of the requests to the server, the default one of gopcua.
+	minRequestTimeout = 10 * time.Second
+)
+
+// keepAliveCount returns the number of the publishing intervals
+// without the notifications after which the server sends the keep-alive message.
+func keepAliveCount(interval time.Duration) uint32 {
+	if interval <= 0 || interval >= keepAliveInterval {
+		return 1
+	}
+
+	return uint32((keepAliveInterval + interval - 1) / interval)
+}
+
+// requestTimeout returns the timeout of the requests to the server long enough
+// for the publish request to be answered with the keep-alive message at the latest.
+func requestTimeout(interval time.Duration) time.Duration {
+	timeout := 2 * time.Duration(keepAliveCount(interval)) * interval
+
+	if timeout < minRequestTimeout {
+		return minRequestTimeout
+	}
+
+	return timeout
+}
+
+// missedMessages returns the number of the notification messages
+// the server has sent between the ones with the given sequence numbers.
+// The sequence numbers start over from 1 after the maximum one.
+func missedMessages(last, next uint32) int {
+	switch {
+	case last == 0 || next == last:
+		return 0
+
+	case next < last:
+		return int(math.MaxUint32-last) + int(next) - 1
+
+	default:
+		return int(next - last - 1)
+	}
+}
+
+// countMessage records the notification message received from the server.
+// The keep-alive messages carry the sequence number of the next message,
+// so they don't advance the last sequence number.
+func (monitor *OpcuaMonitor) countMessage(message *ua.NotificationMessage) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if len(message.NotificationData) == 0 {
+		monitor.keepAlives++
+		return
+	}
+
+	monitor.missedMessages += missedMessages(monitor.sequenceNumber, message.SequenceNumber)
+	monitor.sequenceNumber = message.SequenceNumber
+}
+
+// publish runs the publishing loop of the subscription and sends the notifications
+// to its channel. It's used in place of the loop of gopcua which hides the sequence
+// numbers and the keep-alive messages from the monitor. It returns nil if the context
+// is done and the cause of the failure otherwise.
+func (monitor *OpcuaMonitor) publish(ctx context.Context, connection *opcua.Client,
+	subscription *opcua.Subscription) error {
+	acks := make([]*ua.SubscriptionAcknowledgement, 0)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		default:
+		}
+
+		var res *ua.PublishResponse
+		err := connection.Send(&ua.PublishRequest{SubscriptionAcknowledgements: acks}, func(v interface{}) error {
+			response, ok := v.(*ua.PublishResponse)
+
+			if !ok {
+				return fmt.Errorf("The server returned %T instead of the publish response", v)
+			}
+
+			res = response
+
+			return nil
+		})
+
+		switch err {
+		case nil, ua.StatusBadSequenceNumberUnknown:
+			// The messages have been acknowledged already, the acknowledgements are cleared below.
+
+		case ua.StatusBadTimeout:
+			continue
+
+		default:
+			return err
+		}
+
+		if res == nil {
+			continue
+		}
+
+		// The messages the server still keeps for republishing aren't requested again.
+		acks = make([]*ua.SubscriptionAcknowledgement, 0, len(res.AvailableSequenceNumbers))
+
+		for _, number := range res.AvailableSequenceNumbers {
+			acks = append(acks, &ua.SubscriptionAcknowledgement{
+				SubscriptionID: res.SubscriptionID,
+				SequenceNumber: number,
+			})
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if res.NotificationMessage == nil {
+			return fmt.Errorf("The server returned no notification message")
+		}
+
+		monitor.countMessage(res.NotificationMessage)
+
+		for _, data := range res.NotificationMessage.NotificationData {
+			if data == nil || data.Value == nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+
+			case subscription.Notifs <- &opcua.PublishNotificationData{
+				SubscriptionID: res.SubscriptionID,
+				Value:          data.Value,
+			}:
+			}
+		}
+	}
+}
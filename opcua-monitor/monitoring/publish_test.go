@@ -0,0 +1,54 @@
+package monitoring
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMissedMessages(t *testing.T) {
+	tests := []struct {
+		last, next uint32
+		want       int
+	}{
+		{last: 0, next: 7, want: 0},
+		{last: 7, next: 8, want: 0},
+		{last: 7, next: 10, want: 2},
+		{last: 7, next: 7, want: 0},
+		{last: math.MaxUint32, next: 1, want: 0},
+		{last: math.MaxUint32 - 1, next: 2, want: 2},
+	}
+
+	for _, test := range tests {
+		got := missedMessages(test.last, test.next)
+
+		if got != test.want {
+			t.Errorf("missedMessages(%d, %d) = %d, want %d", test.last, test.next, got, test.want)
+		}
+	}
+}
+
+func TestKeepAliveCount(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     uint32
+	}{
+		{interval: time.Second, want: 5},
+		{interval: 300 * time.Millisecond, want: 17},
+		{interval: 5 * time.Second, want: 1},
+		{interval: time.Minute, want: 1},
+	}
+
+	for _, test := range tests {
+		got := keepAliveCount(test.interval)
+
+		if got != test.want {
+			t.Errorf("keepAliveCount(%v) = %d, want %d", test.interval, got, test.want)
+		}
+
+		// The keep-alive message must arrive before the publish request times out.
+		if timeout := requestTimeout(test.interval); timeout <= time.Duration(got)*test.interval {
+			t.Errorf("requestTimeout(%v) = %v, shorter than the keep-alive interval", test.interval, timeout)
+		}
+	}
+}
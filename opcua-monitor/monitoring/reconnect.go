@@ -0,0 +1,130 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"strings"
+	"time"
+)
+
+const (
+	// minReconnectDelay is the delay before the first attempt to reconnect.
+	minReconnectDelay = 1 * time.Second
+	// maxReconnectDelay is the upper limit of the delay between the attempts to reconnect.
+	maxReconnectDelay = 1 * time.Minute
+)
+
+// reconnect tries to connect to the server with exponential backoff starting
+// with the delay and recreates the subscription with all the monitored items.
+// It returns false if the monitor has been stopped before the connection was restored.
+func (monitor *OpcuaMonitor) reconnect(delay time.Duration) bool {
+
+	for {
+		select {
+		case <-monitor.ctx.Done():
+			monitor.logger.Println("Disconnected from the server.")
+			return false
+
+		case <-monitor.stop:
+			monitor.logger.Println("Monitor stopped")
+			return false
+
+		case <-time.After(delay):
+		}
+
+		monitor.logger.Println("Connecting to the server", strings.Join(monitor.config.Endpoints(), ", "))
+
+		err := monitor.Connect()
+
+		if err == nil {
+			return true
+		}
+
+		delay *= 2
+
+		if delay < minReconnectDelay {
+			delay = minReconnectDelay
+		}
+
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// restoreParameters creates the monitored items for all the parameters
+// and the events in the new subscription keeping their client handles.
+// There's nothing to restore when the monitor connects for the first time.
+func (monitor *OpcuaMonitor) restoreParameters() {
+	handles := make([]uint32, 0, len(monitor.parameters))
+	now := time.Now()
+
+	for handle := range monitor.parameters {
+		handles = append(handles, handle)
+
+		// The lost connection has been reported already, so the parameters
+		// don't become stale before the server sends their values.
+		if state, ok := monitor.freshness[handle]; ok && !state.stale {
+			state.updated = now
+		}
+	}
+
+	// The rejected parameters are monitored again at the retry interval.
+	for handle, err := range monitor.monitorItems(handles) {
+		monitor.logger.Printf("Couldn't restore monitoring of the parameter '%s': %s",
+			monitor.parameters[handle].Name, err)
+	}
+
+	if monitor.eventsMonitored {
+		err := monitor.monitorEvents(monitor.eventHandle, monitor.config.EventNotifier)
+
+		if err != nil {
+			monitor.logger.Println("Couldn't restore monitoring of the events:", err)
+		}
+	}
+}
+
+// closeConnection closes the connection if it's established.
+func (monitor *OpcuaMonitor) closeConnection() {
+	if !monitor.connected {
+		return
+	}
+
+	monitor.connection.Close()
+	monitor.connected = false
+}
+
+// sendConnectionEvent notifies the subscribers about the change of the connection state.
+func (monitor *OpcuaMonitor) sendConnectionEvent(state string, err error) {
+	monitor.synchronizer.Lock()
+	endpoint := monitor.endpoint
+	monitor.synchronizer.Unlock()
+
+	event := data.ConnectionEvent{
+		Server:    monitor.config.Name,
+		Endpoint:  endpoint,
+		State:     state,
+		Timestamp: time.Now(),
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	monitor.fanout.SendMeasurement(event)
+}
+
+// sendFailoverEvent notifies the subscribers that the monitor
+// has switched from the server at the previous endpoint to the backup one.
+func (monitor *OpcuaMonitor) sendFailoverEvent(previous string) {
+	monitor.synchronizer.Lock()
+	endpoint := monitor.endpoint
+	monitor.synchronizer.Unlock()
+
+	monitor.fanout.SendMeasurement(data.ConnectionEvent{
+		Server:           monitor.config.Name,
+		Endpoint:         endpoint,
+		PreviousEndpoint: previous,
+		State:            data.ConnectionFailover,
+		Timestamp:        time.Now(),
+	})
+}
@@ -0,0 +1,338 @@
+package monitoring
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// recordingVersion is the version of the recording format.
+const recordingVersion = 1
+
+// recordingHeader starts the recording.
+type recordingHeader struct {
+	Version int
+	Server  string
+	Started time.Time
+}
+
+// recordingEntry is the data change notification received from the server.
+type recordingEntry struct {
+	// Received is the time the notification was received at.
+	Received time.Time
+	// Parameters are the parameters monitored since the previous entry
+	// or changed since then by the client handles.
+	Parameters map[uint32]Parameter
+	Items      []recordedItem
+}
+
+// recordedItem is the value of the monitored item in the OPC UA binary encoding,
+// so the value, its timestamps and its status code are kept as they were received.
+type recordedItem struct {
+	Handle uint32
+	Value  []byte
+}
+
+// Recorder writes the raw data change notifications of the monitor
+// to the gzip-compressed file, so they can be replayed later.
+type Recorder struct {
+	file         *os.File
+	compressor   *gzip.Writer
+	encoder      *gob.Encoder
+	server       string
+	parameters   map[uint32]Parameter
+	closed       bool
+	synchronizer *sync.Mutex
+}
+
+// NewRecorder creates the recording file of the notifications of the server.
+func NewRecorder(filePath, server string) (*Recorder, error) {
+	file, err := os.Create(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	compressor := gzip.NewWriter(file)
+	recorder := &Recorder{
+		file:         file,
+		compressor:   compressor,
+		encoder:      gob.NewEncoder(compressor),
+		server:       server,
+		parameters:   make(map[uint32]Parameter),
+		synchronizer: new(sync.Mutex),
+	}
+
+	err = recorder.encoder.Encode(recordingHeader{
+		Version: recordingVersion,
+		Server:  server,
+		Started: time.Now(),
+	})
+
+	if err == nil {
+		err = compressor.Flush()
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+// Close flushes the recording and closes the file. The notifications
+// aren't recorded any more, and closing the recorder again does nothing.
+func (recorder *Recorder) Close() error {
+	recorder.synchronizer.Lock()
+	defer recorder.synchronizer.Unlock()
+
+	if recorder.closed {
+		return nil
+	}
+
+	recorder.closed = true
+	err := recorder.compressor.Close()
+
+	if err != nil {
+		recorder.file.Close()
+		return err
+	}
+
+	return recorder.file.Close()
+}
+
+// record writes the notification along with the parameters of its items
+// which haven't been written yet or have changed since they were written.
+// The notification is flushed to the file at once, so only the last one
+// may be lost if the monitor is killed.
+func (recorder *Recorder) record(parameters map[uint32]Parameter, message *ua.DataChangeNotification) error {
+	recorder.synchronizer.Lock()
+	defer recorder.synchronizer.Unlock()
+
+	// The notifications received while the monitor is stopping aren't recorded.
+	if recorder.closed {
+		return nil
+	}
+
+	entry := recordingEntry{
+		Received: time.Now(),
+		Items:    make([]recordedItem, 0, len(message.MonitoredItems)),
+	}
+
+	for _, item := range message.MonitoredItems {
+		if item.Value == nil {
+			continue
+		}
+
+		value, err := item.Value.Encode()
+
+		if err != nil {
+			return err
+		}
+
+		entry.Items = append(entry.Items, recordedItem{Handle: item.ClientHandle, Value: value})
+
+		parameter := parameters[item.ClientHandle]
+
+		if recorded, ok := recorder.parameters[item.ClientHandle]; ok && reflect.DeepEqual(recorded, parameter) {
+			continue
+		}
+
+		if entry.Parameters == nil {
+			entry.Parameters = make(map[uint32]Parameter)
+		}
+
+		entry.Parameters[item.ClientHandle] = parameter
+		recorder.parameters[item.ClientHandle] = parameter
+	}
+
+	err := recorder.encoder.Encode(entry)
+
+	if err != nil {
+		return err
+	}
+
+	return recorder.compressor.Flush()
+}
+
+// EnableRecording makes the monitor write every data change notification
+// received from the server to the recorder.
+func (monitor *OpcuaMonitor) EnableRecording(recorder *Recorder) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	monitor.recorder = recorder
+}
+
+// StartRecording creates the recording file and makes the monitor write
+// the notifications of the server to it until the returned recorder is closed.
+func (monitor *OpcuaMonitor) StartRecording(filePath string) (io.Closer, error) {
+	recorder, err := NewRecorder(filePath, monitor.config.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.EnableRecording(recorder)
+
+	return recorder, nil
+}
+
+// Recording reads the notifications written by the recorder.
+type Recording struct {
+	file         *os.File
+	decompressor *gzip.Reader
+	decoder      *gob.Decoder
+	header       recordingHeader
+	// truncated is true if the recording ends in the middle of the notification.
+	truncated bool
+}
+
+// OpenRecording opens the recording file and reads its header.
+func OpenRecording(filePath string) (*Recording, error) {
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decompressor, err := gzip.NewReader(file)
+
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	recording := &Recording{
+		file:         file,
+		decompressor: decompressor,
+		decoder:      gob.NewDecoder(decompressor),
+	}
+
+	err = recording.decoder.Decode(&recording.header)
+
+	if err == nil && recording.header.Version != recordingVersion {
+		err = fmt.Errorf("unsupported recording version %d", recording.header.Version)
+	}
+
+	if err != nil {
+		recording.Close()
+		return nil, fmt.Errorf("%s: %s", filePath, err)
+	}
+
+	return recording, nil
+}
+
+// Server returns the name of the server the notifications have been received from.
+func (recording *Recording) Server() string {
+	return recording.header.Server
+}
+
+// Started returns the time the recording has been started at.
+func (recording *Recording) Started() time.Time {
+	return recording.header.Started
+}
+
+// Close closes the recording file.
+func (recording *Recording) Close() error {
+	recording.decompressor.Close()
+	return recording.file.Close()
+}
+
+// next reads the next notification. It returns io.EOF at the end of the recording.
+func (recording *Recording) next() (recordingEntry, *ua.DataChangeNotification, error) {
+	var entry recordingEntry
+	err := recording.decoder.Decode(&entry)
+
+	// The recording isn't closed properly if the monitor has been killed.
+	if err == io.ErrUnexpectedEOF {
+		recording.truncated = true
+		err = io.EOF
+	}
+
+	if err != nil {
+		return entry, nil, err
+	}
+
+	message := &ua.DataChangeNotification{
+		MonitoredItems: make([]*ua.MonitoredItemNotification, 0, len(entry.Items)),
+	}
+
+	for _, item := range entry.Items {
+		value := new(ua.DataValue)
+		_, err = value.Decode(item.Value)
+
+		if err != nil {
+			return entry, nil, err
+		}
+
+		message.MonitoredItems = append(message.MonitoredItems, &ua.MonitoredItemNotification{
+			ClientHandle: item.Handle,
+			Value:        value,
+		})
+	}
+
+	return entry, message, nil
+}
+
+// Replay sends the notifications of the recording to the subscribers of the monitor
+// the same way as if they were received from the server. The intervals between
+// the notifications are divided by the speed, e.g. the speed 10 replays the recording
+// ten times faster, and the notifications are sent without waiting if the speed is zero.
+// Each notification is delivered to all the subscribers before the next one is sent,
+// so they receive the notifications in the recorded order.
+// The monitor mustn't be started since the parameters are replaced with the recorded ones.
+func (monitor *OpcuaMonitor) Replay(recording *Recording, speed float64) error {
+	var previous time.Time
+	count := 0
+
+	for {
+		entry, message, err := recording.next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if speed > 0 && !previous.IsZero() {
+			delay := time.Duration(float64(entry.Received.Sub(previous)) / speed)
+
+			select {
+			case <-monitor.ctx.Done():
+				return monitor.ctx.Err()
+
+			case <-time.After(delay):
+			}
+		}
+
+		previous = entry.Received
+
+		monitor.synchronizer.Lock()
+		for handle, parameter := range entry.Parameters {
+			monitor.parameters[handle] = parameter
+		}
+		monitor.synchronizer.Unlock()
+
+		monitor.fanout.SendMeasurementSync(monitor.parametersState(message))
+		count++
+	}
+
+	if recording.truncated {
+		monitor.logger.Printf("The recording of the server '%s' is truncated", recording.Server())
+	}
+
+	monitor.logger.Printf("Replayed %d notifications of the server '%s'", count, recording.Server())
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// recordNotifications writes the notifications with the values from 0 to count - 1
+// of the parameter with the handle 1 and returns the time of the first one.
+func recordNotifications(t *testing.T, recorder *Recorder, count int) time.Time {
+	parameters := map[uint32]Parameter{1: {Name: "Temperature", NodeID: "ns=2;s=Temperature"}}
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < count; i++ {
+		err := recorder.record(parameters, &ua.DataChangeNotification{
+			MonitoredItems: []*ua.MonitoredItemNotification{
+				{
+					ClientHandle: 1,
+					Value: &ua.DataValue{
+						EncodingMask:    ua.DataValueValue | ua.DataValueSourceTimestamp,
+						Value:           ua.MustVariant(float64(i)),
+						SourceTimestamp: started.Add(time.Duration(i) * time.Second),
+					},
+				},
+			},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return started
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	filePath, remove := writeTempFile(t, "server.rec", "")
+	defer remove()
+
+	recorder, err := NewRecorder(filePath, "server")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := recordNotifications(t, recorder, 3)
+
+	err = recorder.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recording, err := OpenRecording(filePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recording.Close()
+
+	if recording.Server() != "server" {
+		t.Errorf("Server() = %q, want %q", recording.Server(), "server")
+	}
+
+	for i := 0; i < 3; i++ {
+		entry, message, err := recording.next()
+
+		if err != nil {
+			t.Fatalf("next() failed on the notification %d: %s", i, err)
+		}
+
+		// The parameters are written along with their first notification only.
+		if i == 0 && entry.Parameters[1].Name != "Temperature" {
+			t.Errorf("The first notification has the parameters %+v, want Temperature", entry.Parameters)
+		}
+
+		if i > 0 && len(entry.Parameters) != 0 {
+			t.Errorf("The notification %d has the parameters %+v, want none", i, entry.Parameters)
+		}
+
+		if len(message.MonitoredItems) != 1 {
+			t.Fatalf("The notification %d has %d items, want 1", i, len(message.MonitoredItems))
+		}
+
+		item := message.MonitoredItems[0]
+
+		if value, ok := item.Value.Value.Value().(float64); item.ClientHandle != 1 || !ok || value != float64(i) {
+			t.Errorf("The notification %d has the value %v of the item %d, want %d of the item 1",
+				i, item.Value.Value.Value(), item.ClientHandle, i)
+		}
+
+		if want := started.Add(time.Duration(i) * time.Second); !item.Value.SourceTimestamp.Equal(want) {
+			t.Errorf("The notification %d has the timestamp %v, want %v", i, item.Value.SourceTimestamp, want)
+		}
+	}
+
+	_, _, err = recording.next()
+
+	if err != io.EOF || recording.truncated {
+		t.Errorf("next() = %v at the end (truncated %v), want io.EOF", err, recording.truncated)
+	}
+}
+
+func TestRecordingTruncated(t *testing.T) {
+	filePath, remove := writeTempFile(t, "server.rec", "")
+	defer remove()
+
+	recorder, err := NewRecorder(filePath, "server")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	// The recorder isn't closed as if the monitor has been killed.
+	recordNotifications(t, recorder, 2)
+
+	recording, err := OpenRecording(filePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recording.Close()
+
+	for i := 0; i < 2; i++ {
+		_, _, err = recording.next()
+
+		if err != nil {
+			t.Fatalf("next() failed on the notification %d: %s", i, err)
+		}
+	}
+
+	_, _, err = recording.next()
+
+	if err != io.EOF || !recording.truncated {
+		t.Errorf("next() = %v at the end (truncated %v), want io.EOF of the truncated recording",
+			err, recording.truncated)
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	filePath, remove := writeTempFile(t, "server.rec", "")
+	defer remove()
+
+	recorder, err := NewRecorder(filePath, "server")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recordNotifications(t, recorder, 50)
+
+	err = recorder.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recording, err := OpenRecording(filePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recording.Close()
+
+	config := DefaultConnectionConfig()
+	config.Name = "server"
+	monitor := NewOpcuaMonitor(context.Background(), config, log.New(ioutil.Discard, "", 0), 0)
+
+	channel := make(chan data.Measurement)
+	monitor.AddSubscriber(channel)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- monitor.Replay(recording, 0)
+	}()
+
+	for i := 0; i < 50; i++ {
+		state, ok := (<-channel).(data.ParametersState)
+
+		if !ok {
+			t.Fatalf("The measurement %d is not the state of the parameters", i)
+		}
+
+		if value := state.Parameters["Temperature"].Number; value != float64(i) {
+			t.Fatalf("The measurement %d has the value %v, want %d", i, value, i)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Replay() failed: %s", err)
+	}
+}
@@ -0,0 +1,294 @@
+package monitoring
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// defaultMinServiceLevel is the lowest ServiceLevel of the healthy server.
+	// The levels from 1 to 199 mean the server is degraded, 0 means it's in maintenance.
+	defaultMinServiceLevel = 200
+	// healthCheckInterval is the interval of reading the ServiceLevel and the state of the active server.
+	healthCheckInterval = 5 * time.Second
+	// failoverProbeInterval is the interval of connecting to the backup servers
+	// to check if any of them is healthy while the active server is degraded.
+	failoverProbeInterval = time.Minute
+)
+
+// failoverError is the reason to switch to another server without waiting
+// before reconnecting, so the gap in the parameter updates is minimal.
+type failoverError struct {
+	reason error
+}
+
+func (err *failoverError) Error() string {
+	return err.reason.Error()
+}
+
+// failoverProbe is the healthy backup server found while the active one is degraded.
+type failoverProbe struct {
+	// active is the endpoint of the server which was active when the probe started.
+	active string
+	err    *failoverError
+}
+
+// Endpoints returns the endpoints of the redundant servers in order of preference.
+func (config ConnectionConfig) Endpoints() []string {
+	return append([]string{config.Endpoint}, config.BackupEndpoints...)
+}
+
+// dialBest connects to the first healthy server in order of preference.
+// If none of the servers is healthy, it connects to the one with the highest ServiceLevel.
+// It returns the connection and the endpoint of the server.
+func (monitor *OpcuaMonitor) dialBest() (*opcua.Client, string, error) {
+	endpoints := monitor.config.Endpoints()
+
+	// There's nothing to choose from without the backup servers.
+	if len(endpoints) == 1 {
+		connection, err := monitor.dial(endpoints[0])
+		return connection, endpoints[0], err
+	}
+
+	var best *opcua.Client
+	var bestEndpoint string
+	var lastErr error
+	bestLevel := -1
+
+	for _, endpoint := range endpoints {
+		connection, err := monitor.dial(endpoint)
+
+		if err != nil {
+			monitor.logger.Printf("Couldn't connect to the server %s: %s", endpoint, err)
+			lastErr = err
+			continue
+		}
+
+		level := serviceLevel(connection)
+
+		if level >= monitor.config.MinServiceLevel {
+			if best != nil {
+				best.Close()
+			}
+
+			return connection, endpoint, nil
+		}
+
+		monitor.logger.Printf("The server %s is degraded: ServiceLevel %d", endpoint, level)
+
+		if level > bestLevel {
+			if best != nil {
+				best.Close()
+			}
+
+			best, bestEndpoint, bestLevel = connection, endpoint, level
+		} else {
+			connection.Close()
+		}
+	}
+
+	if best == nil {
+		return nil, "", lastErr
+	}
+
+	monitor.logger.Printf("None of the servers '%s' is healthy, using the server %s", monitor.config.Name, bestEndpoint)
+
+	return best, bestEndpoint, nil
+}
+
+// checkHealth reads the ServiceLevel and the state of the active server. It returns
+// the reason to fail over if the server isn't running. If the server is degraded,
+// the backup servers are probed in the background and the reason to fail over
+// is sent to the probes channel as soon as one of them is found healthy.
+func (monitor *OpcuaMonitor) checkHealth() error {
+	monitor.synchronizer.Lock()
+	connection := monitor.connection
+	active := monitor.endpoint
+	monitor.synchronizer.Unlock()
+
+	level, state, err := readServerHealth(connection)
+
+	if err != nil {
+		return err
+	}
+
+	if state != ua.ServerStateRunning {
+		return &failoverError{fmt.Errorf("The server %s is not running: %s", active, state)}
+	}
+
+	// There's no server to fail over to, so the ServiceLevel doesn't matter.
+	if len(monitor.config.BackupEndpoints) == 0 {
+		return nil
+	}
+
+	healthy := level >= monitor.config.MinServiceLevel
+
+	monitor.synchronizer.Lock()
+	degraded := monitor.degraded
+	monitor.degraded = !healthy
+	probe := !healthy && !monitor.probing && time.Since(monitor.failoverProbed) >= failoverProbeInterval
+
+	if probe {
+		monitor.probing = true
+		monitor.failoverProbed = time.Now()
+	}
+	monitor.synchronizer.Unlock()
+
+	if healthy {
+		if degraded {
+			monitor.logger.Printf("The server %s is healthy again: ServiceLevel %d", active, level)
+		}
+
+		return nil
+	}
+
+	if !degraded {
+		monitor.logger.Printf("The server %s is degraded: ServiceLevel %d", active, level)
+	}
+
+	// Dialing the backup servers takes a while, so the parameter updates aren't held up.
+	if probe {
+		go monitor.probeBackups(active, level)
+	}
+
+	return nil
+}
+
+// probeBackups looks for the healthy backup server and sends the reason
+// to fail over to it to the probes channel if there's one.
+func (monitor *OpcuaMonitor) probeBackups(active string, level int) {
+	backup := monitor.findHealthyServer(active)
+
+	monitor.synchronizer.Lock()
+	monitor.probing = false
+	monitor.synchronizer.Unlock()
+
+	if backup == "" {
+		return
+	}
+
+	probe := failoverProbe{
+		active: active,
+		err: &failoverError{fmt.Errorf("The server %s is degraded (ServiceLevel %d), the server %s is healthy",
+			active, level, backup)},
+	}
+
+	// The previous result hasn't been handled yet if the channel is full.
+	select {
+	case monitor.probes <- probe:
+	default:
+	}
+}
+
+// checkProbe returns the reason to fail over found by probing the backup servers
+// or nil if the monitor has switched to another server or the active one
+// has become healthy since the probe started.
+func (monitor *OpcuaMonitor) checkProbe(probe failoverProbe) error {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if !monitor.degraded || monitor.endpoint != probe.active {
+		return nil
+	}
+
+	return probe.err
+}
+
+// findHealthyServer connects to the servers other than the active one and returns
+// the endpoint of the first healthy one or the empty string if none of them is healthy.
+func (monitor *OpcuaMonitor) findHealthyServer(active string) string {
+	for _, endpoint := range monitor.config.Endpoints() {
+		if endpoint == active {
+			continue
+		}
+
+		connection, err := monitor.dial(endpoint)
+
+		if err != nil {
+			continue
+		}
+
+		level := serviceLevel(connection)
+		connection.Close()
+
+		if level >= monitor.config.MinServiceLevel {
+			return endpoint
+		}
+	}
+
+	return ""
+}
+
+// serviceLevel returns the ServiceLevel of the server. The server which isn't running
+// or doesn't provide its ServiceLevel is considered unable to serve the data.
+func serviceLevel(connection *opcua.Client) int {
+	level, state, err := readServerHealth(connection)
+
+	if err != nil || state != ua.ServerStateRunning {
+		return 0
+	}
+
+	return level
+}
+
+// readServerHealth reads the ServiceLevel and the state of the server with a single request.
+func readServerHealth(connection *opcua.Client) (int, ua.ServerState, error) {
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{
+				NodeID:       ua.NewNumericNodeID(0, id.Server_ServiceLevel),
+				AttributeID:  ua.AttributeIDValue,
+				DataEncoding: &ua.QualifiedName{},
+			},
+			{
+				NodeID:       ua.NewNumericNodeID(0, id.Server_ServerStatus_State),
+				AttributeID:  ua.AttributeIDValue,
+				DataEncoding: &ua.QualifiedName{},
+			},
+		},
+	})
+
+	if err != nil {
+		return 0, ua.ServerStateUnknown, err
+	}
+
+	if len(res.Results) != 2 {
+		return 0, ua.ServerStateUnknown, fmt.Errorf("The server returned %d results for 2 nodes", len(res.Results))
+	}
+
+	for _, result := range res.Results {
+		if result.Status != ua.StatusOK {
+			return 0, ua.ServerStateUnknown, fmt.Errorf("Couldn't read the server status: %s", statusName(result.Status))
+		}
+
+		if result.Value == nil {
+			return 0, ua.ServerStateUnknown, fmt.Errorf("The server returned no status")
+		}
+	}
+
+	level, ok := res.Results[0].Value.Value().(byte)
+
+	if !ok {
+		return 0, ua.ServerStateUnknown, fmt.Errorf("The ServiceLevel is not a byte")
+	}
+
+	// The enumeration is encoded as the 32-bit integer.
+	var state ua.ServerState
+
+	switch value := res.Results[1].Value.Value().(type) {
+	case int32:
+		state = ua.ServerState(value)
+
+	case uint32:
+		state = ua.ServerState(value)
+
+	default:
+		return 0, ua.ServerStateUnknown, fmt.Errorf("The server state is not an enumeration")
+	}
+
+	return int(level), state, nil
+}
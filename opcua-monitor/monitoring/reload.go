@@ -0,0 +1,124 @@
+package monitoring
+
+import (
+	"biocad-opcua/shared"
+	"reflect"
+)
+
+// ReloadParameters obtains the parameters from the parameter source again and brings
+// the monitored items in line with them without interrupting the monitoring
+// of the unchanged parameters. The parameters are identified by their names.
+// The monitored parameters are kept if the parameter source can't be read.
+func (monitor *OpcuaMonitor) ReloadParameters() (shared.ParameterChanges, error) {
+	changes := shared.ParameterChanges{}
+	parameters, err := monitor.loadParameters()
+
+	if err != nil {
+		return changes, err
+	}
+
+	monitor.synchronizer.Lock()
+	for _, parameter := range parameters {
+		monitor.loaded[parameter.Name] = parameter
+	}
+
+	current := make(map[string]Parameter, len(monitor.parameters))
+
+	for _, parameter := range monitor.parameters {
+		current[parameter.Name] = parameter
+	}
+	monitor.synchronizer.Unlock()
+
+	wanted := make(map[string]bool, len(parameters))
+
+	for _, parameter := range parameters {
+		wanted[parameter.Name] = true
+	}
+
+	for name, parameter := range current {
+		if wanted[name] {
+			continue
+		}
+
+		err = monitor.UnmonitorParameter(name)
+
+		if err != nil {
+			monitor.handleReloadError(parameter.Name, err)
+			continue
+		}
+
+		changes.Removed = append(changes.Removed, parameter.neutral())
+	}
+
+	// The new monitored items are created together.
+	pending := make([]Parameter, 0)
+	recreated := make(map[string]bool)
+
+	for _, parameter := range parameters {
+		old, ok := current[parameter.Name]
+
+		switch {
+		case !ok:
+			pending = append(pending, parameter)
+
+		case reflect.DeepEqual(old, parameter):
+			continue
+
+		case old.NodeID != parameter.NodeID || old.Sampling != parameter.Sampling:
+			// The monitored item has to be created again with the new settings.
+			err = monitor.UnmonitorParameter(parameter.Name)
+
+			if err != nil {
+				monitor.handleReloadError(parameter.Name, err)
+				continue
+			}
+
+			pending = append(pending, parameter)
+			recreated[parameter.Name] = true
+
+		default:
+			// The monitored item isn't affected by the rest of the definition.
+			monitor.replaceParameter(parameter)
+			changes.Updated = append(changes.Updated, parameter.neutral())
+		}
+	}
+
+	for _, result := range monitor.monitorParameters(pending) {
+		parameter := result.Parameter
+
+		// The parameter rejected by the server is monitored as soon as the server accepts it.
+		if result.Err != nil && !result.Retrying {
+			monitor.handleReloadError(parameter.Name, result.Err)
+			continue
+		}
+
+		if result.Err != nil {
+			monitor.logger.Printf("Couldn't monitor the parameter '%s', retrying in %v: %s",
+				parameter.Name, itemRetryInterval, result.Err)
+		}
+
+		if recreated[parameter.Name] {
+			changes.Updated = append(changes.Updated, parameter)
+		} else {
+			changes.Added = append(changes.Added, parameter)
+		}
+	}
+
+	return changes, nil
+}
+
+// replaceParameter replaces the definition of the monitored parameter with the same name.
+func (monitor *OpcuaMonitor) replaceParameter(parameter Parameter) {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if handle, ok := monitor.handleByName(parameter.Name); ok {
+		monitor.parameters[handle] = parameter
+	}
+}
+
+func (monitor *OpcuaMonitor) handleReloadError(name string, err error) {
+	if err != nil {
+		monitor.logger.Printf("Couldn't reload the parameter '%s': %s", name, err)
+	}
+}
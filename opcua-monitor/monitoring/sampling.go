@@ -0,0 +1,207 @@
+package monitoring
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// SamplingConfig describes how the server samples the parameter
+// and which changes of the parameter it reports.
+type SamplingConfig struct {
+	// Interval is the sampling interval. Zero means the fastest
+	// rate the server supports, a negative interval means the
+	// publishing interval of the subscription.
+	Interval time.Duration
+	// QueueSize is the number of values the server keeps
+	// for the parameter between the publishing cycles.
+	QueueSize uint32
+	// DiscardOldest makes the server drop the oldest value
+	// instead of the newest one when the queue is full.
+	DiscardOldest bool
+	// Deadband is the deadband type: None, Absolute or Percent.
+	Deadband string
+	// DeadbandValue is the change of the value (absolute or in percents
+	// of the EURange) which is not reported by the server.
+	DeadbandValue float64
+	// Trigger is the change that makes the server report the value:
+	// Status, StatusValue or StatusValueTimestamp.
+	Trigger string
+}
+
+// DefaultSampling returns the sampling settings used for the parameters
+// that don't have their own ones.
+func DefaultSampling() SamplingConfig {
+	return SamplingConfig{
+		Interval:      0,
+		QueueSize:     10,
+		DiscardOldest: true,
+		Deadband:      "None",
+		Trigger:       "StatusValue",
+	}
+}
+
+// apply writes the sampling settings to the monitored item parameters.
+func (sampling SamplingConfig) apply(params *ua.MonitoringParameters) error {
+	filter, err := sampling.filter()
+
+	if err != nil {
+		return err
+	}
+
+	params.SamplingInterval = float64(sampling.Interval) / float64(time.Millisecond)
+	params.QueueSize = sampling.QueueSize
+	params.DiscardOldest = sampling.DiscardOldest
+	params.Filter = filter
+
+	if sampling.Interval < 0 {
+		params.SamplingInterval = -1
+	}
+
+	return nil
+}
+
+// filter returns the data change filter for the monitored item.
+// The filter is nil if the server defaults are used.
+func (sampling SamplingConfig) filter() (*ua.ExtensionObject, error) {
+	var deadband ua.DeadbandType
+
+	switch strings.ToLower(sampling.Deadband) {
+	case "", "none":
+		deadband = ua.DeadbandTypeNone
+
+	case "absolute":
+		deadband = ua.DeadbandTypeAbsolute
+
+	case "percent":
+		deadband = ua.DeadbandTypePercent
+
+	default:
+		return nil, fmt.Errorf("Unknown deadband type '%s'", sampling.Deadband)
+	}
+
+	var trigger ua.DataChangeTrigger
+
+	switch strings.ToLower(sampling.Trigger) {
+	case "", "statusvalue":
+		trigger = ua.DataChangeTriggerStatusValue
+
+	case "status":
+		trigger = ua.DataChangeTriggerStatus
+
+	case "statusvaluetimestamp":
+		trigger = ua.DataChangeTriggerStatusValueTimestamp
+
+	default:
+		return nil, fmt.Errorf("Unknown data change trigger '%s'", sampling.Trigger)
+	}
+
+	if deadband == ua.DeadbandTypeNone && trigger == ua.DataChangeTriggerStatusValue {
+		return nil, nil
+	}
+
+	return &ua.ExtensionObject{
+		TypeID:       ua.NewFourByteExpandedNodeID(0, id.DataChangeFilter_Encoding_DefaultBinary),
+		EncodingMask: ua.ExtensionObjectBinary,
+		Value: &ua.DataChangeFilter{
+			Trigger:       trigger,
+			DeadbandType:  uint32(deadband),
+			DeadbandValue: sampling.DeadbandValue,
+		},
+	}, nil
+}
+
+// parseOption sets the sampling setting from the 'key=value' option.
+// The supported options are:
+//
+//	sampling=100ms
+//	queue=5
+//	discard=oldest|newest
+//	deadband=absolute:0.5|percent:0.5
+//	trigger=status|statusvalue|statusvaluetimestamp
+func (sampling *SamplingConfig) parseOption(option string) error {
+	tokens := strings.SplitN(option, "=", 2)
+
+	if len(tokens) < 2 {
+		return fmt.Errorf("Invalid option '%s'", option)
+	}
+
+	key, value := strings.ToLower(tokens[0]), tokens[1]
+
+	switch key {
+	case "sampling":
+		interval, err := time.ParseDuration(value)
+
+		if err != nil {
+			return err
+		}
+
+		sampling.Interval = interval
+
+	case "queue":
+		size, err := strconv.ParseUint(value, 10, 32)
+
+		if err != nil {
+			return err
+		}
+
+		sampling.QueueSize = uint32(size)
+
+	case "discard":
+		switch strings.ToLower(value) {
+		case "oldest":
+			sampling.DiscardOldest = true
+
+		case "newest":
+			sampling.DiscardOldest = false
+
+		default:
+			return fmt.Errorf("Invalid discard policy '%s'", value)
+		}
+
+	case "deadband":
+		parts := strings.SplitN(value, ":", 2)
+
+		if len(parts) < 2 {
+			return fmt.Errorf("Invalid deadband '%s'", value)
+		}
+
+		deadbandValue, err := strconv.ParseFloat(parts[1], 64)
+
+		if err != nil {
+			return err
+		}
+
+		// The negation also rejects NaN.
+		if !(deadbandValue >= 0) {
+			return fmt.Errorf("Invalid deadband '%s': the value is negative", value)
+		}
+
+		switch strings.ToLower(parts[0]) {
+		case "none", "absolute":
+
+		case "percent":
+			if deadbandValue > 100 {
+				return fmt.Errorf("Invalid deadband '%s': the percent is out of range [0, 100]", value)
+			}
+
+		default:
+			return fmt.Errorf("Unknown deadband type '%s'", parts[0])
+		}
+
+		sampling.Deadband = parts[0]
+		sampling.DeadbandValue = deadbandValue
+
+	case "trigger":
+		sampling.Trigger = value
+
+	default:
+		return fmt.Errorf("Unknown option '%s'", key)
+	}
+
+	return nil
+}
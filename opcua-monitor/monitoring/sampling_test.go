@@ -0,0 +1,100 @@
+package monitoring
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingParseOption(t *testing.T) {
+	tests := []struct {
+		option string
+		want   SamplingConfig
+		fails  bool
+	}{
+		{option: "sampling=100ms", want: SamplingConfig{Interval: 100 * time.Millisecond}},
+		{option: "sampling=fast", fails: true},
+		{option: "queue=5", want: SamplingConfig{QueueSize: 5}},
+		{option: "queue=-1", fails: true},
+		{option: "discard=newest", want: SamplingConfig{DiscardOldest: false}},
+		{option: "discard=oldest", want: SamplingConfig{DiscardOldest: true}},
+		{option: "discard=random", fails: true},
+		{option: "deadband=absolute:0.5", want: SamplingConfig{Deadband: "absolute", DeadbandValue: 0.5}},
+		{option: "deadband=Percent:100", want: SamplingConfig{Deadband: "Percent", DeadbandValue: 100}},
+		{option: "deadband=percent:0", want: SamplingConfig{Deadband: "percent"}},
+		{option: "deadband=absolute:-0.5", fails: true},
+		{option: "deadband=percent:-1", fails: true},
+		{option: "deadband=percent:100.5", fails: true},
+		{option: "deadband=absolute:NaN", fails: true},
+		{option: "deadband=relative:5", fails: true},
+		{option: "deadband=absolute", fails: true},
+		{option: "trigger=status", want: SamplingConfig{Trigger: "status"}},
+		{option: "color=red", fails: true},
+		{option: "sampling", fails: true},
+	}
+
+	for _, test := range tests {
+		var sampling SamplingConfig
+		err := sampling.parseOption(test.option)
+
+		if test.fails {
+			if err == nil {
+				t.Errorf("parseOption(%q) succeeded, want an error", test.option)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseOption(%q) failed: %s", test.option, err)
+			continue
+		}
+
+		if sampling != test.want {
+			t.Errorf("parseOption(%q) = %+v, want %+v", test.option, sampling, test.want)
+		}
+	}
+}
+
+func TestLoadParametersFromFileErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		// message is the part of the error identifying the invalid line.
+		message string
+	}{
+		{"ns=3;s=Volume\nns=3;s=Level deadband=absolute:-1\n", ":2: parameter 'Level': "},
+		{"ns=3;s=Volume deadband=percent:150\n", ":1: parameter 'Volume': "},
+		{"\nns=3;s=Volume\n\nns=3;s=Setpoint limits=40:20\n", ":4: parameter 'Setpoint': "},
+		{"ns=3;s=Volume unknown\n", ":1: parameter 'Volume': "},
+	}
+
+	dir, err := ioutil.TempDir("", "parameters")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, test := range tests {
+		filePath := filepath.Join(dir, "parameters.txt")
+		err = ioutil.WriteFile(filePath, []byte(test.content), 0666)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = LoadParametersFromFile(filePath)
+
+		if err == nil {
+			t.Errorf("#%d: LoadParametersFromFile succeeded, want an error", i)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), filePath+test.message) {
+			t.Errorf("#%d: LoadParametersFromFile error %q doesn't contain %q", i, err, test.message)
+		}
+	}
+}
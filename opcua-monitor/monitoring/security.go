@@ -0,0 +1,246 @@
+package monitoring
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+const (
+	// certificateKeySize is the RSA key size of the generated certificate.
+	certificateKeySize = 2048
+	// certificateLifetime is the validity period of the generated certificate.
+	certificateLifetime = 5 * 365 * 24 * time.Hour
+)
+
+// SecurityConfig holds the settings of the secure channel
+// between the monitor and the OPC UA server.
+type SecurityConfig struct {
+	// Policy is the name (Basic256Sha256, etc.) or the URI of the security policy.
+	// Any policy is accepted if it's Auto.
+	Policy string
+	// Mode is the message security mode: None, Sign, SignAndEncrypt or Auto (any).
+	Mode string
+	// CertificateFile is the path to the application instance certificate.
+	CertificateFile string
+	// PrivateKeyFile is the path to the private key of the certificate.
+	PrivateKeyFile string
+	// TrustedCertsDir is the directory with the trusted server certificates.
+	// Any server certificate is accepted if the directory is not specified.
+	TrustedCertsDir string
+	// ApplicationURI is written to the generated certificate.
+	ApplicationURI string
+	// GenerateCertificate makes the monitor create a self-signed
+	// certificate if there's no certificate at the specified path.
+	GenerateCertificate bool
+}
+
+// options returns the client options to establish the secure channel through
+// the endpoint and to activate the session with the given user identity token type.
+func (security SecurityConfig) options(endpoint *ua.EndpointDescription,
+	tokenType ua.UserTokenType) ([]opcua.Option, error) {
+	opts := make([]opcua.Option, 0)
+
+	if endpoint.SecurityMode != ua.MessageSecurityModeNone {
+		certOpts, err := security.certificateOptions()
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, certOpts...)
+	}
+
+	return append(opts, opcua.SecurityFromEndpoint(endpoint, tokenType)), nil
+}
+
+// certificateOptions loads the application instance certificate and its private key
+// generating them first if required.
+func (security SecurityConfig) certificateOptions() ([]opcua.Option, error) {
+	if security.GenerateCertificate {
+		if _, err := os.Stat(security.CertificateFile); os.IsNotExist(err) {
+			err = GenerateCertificate(security.CertificateFile,
+				security.PrivateKeyFile, security.ApplicationURI)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	pair, err := tls.LoadX509KeyPair(security.CertificateFile, security.PrivateKeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := pair.PrivateKey.(*rsa.PrivateKey)
+
+	if !ok {
+		return nil, fmt.Errorf("The private key is not an RSA key")
+	}
+
+	return []opcua.Option{
+		opcua.PrivateKey(privateKey),
+		opcua.Certificate(pair.Certificate[0]),
+	}, nil
+}
+
+// verifyServerCertificate checks if the server certificate
+// is present in the trusted certificates directory.
+func (security SecurityConfig) verifyServerCertificate(certificate []byte) error {
+	if security.TrustedCertsDir == "" {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(security.TrustedCertsDir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(security.TrustedCertsDir, file.Name()))
+
+		if err != nil {
+			return err
+		}
+
+		// Certificates may be stored both in DER and PEM format.
+		if block, _ := pem.Decode(contents); block != nil {
+			contents = block.Bytes
+		}
+
+		if bytes.Equal(contents, certificate) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The server certificate is not trusted")
+}
+
+// GenerateCertificate creates a self-signed application instance
+// certificate and its private key and writes them in PEM format.
+func GenerateCertificate(certFile, keyFile, applicationURI string) error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, certificateKeySize)
+
+	if err != nil {
+		return err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+	if err != nil {
+		return err
+	}
+
+	uri, err := url.Parse(applicationURI)
+
+	if err != nil {
+		return err
+	}
+
+	host, err := os.Hostname()
+
+	if err != nil {
+		return err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   "opcua-monitor",
+			Organization: []string{"biocad-opcua"},
+		},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(certificateLifetime),
+		KeyUsage: x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment |
+			x509.KeyUsageDigitalSignature | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{host},
+		URIs:                  []*url.URL{uri},
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template,
+		&privateKey.PublicKey, privateKey)
+
+	if err != nil {
+		return err
+	}
+
+	err = writePEM(certFile, "CERTIFICATE", certificate, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	return writePEM(keyFile, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey), 0600)
+}
+
+// writePEM writes the PEM-encoded block to the file creating its directory.
+func writePEM(path, blockType string, contents []byte, perm os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return pem.Encode(file, &pem.Block{
+		Type:  blockType,
+		Bytes: contents,
+	})
+}
+
+// securityPolicyURI returns the URI of the security policy with the given name.
+func securityPolicyURI(policy string) string {
+	if policy == "" {
+		return ua.SecurityPolicyURINone
+	}
+
+	if strings.HasPrefix(policy, ua.SecurityPolicyURIPrefix) {
+		return policy
+	}
+
+	return ua.SecurityPolicyURIPrefix + policy
+}
+
+// securityMode returns the message security mode with the given name.
+func securityMode(mode string) ua.MessageSecurityMode {
+	switch strings.ToLower(mode) {
+	case "sign":
+		return ua.MessageSecurityModeSign
+
+	case "signandencrypt":
+		return ua.MessageSecurityModeSignAndEncrypt
+
+	default:
+		return ua.MessageSecurityModeNone
+	}
+}
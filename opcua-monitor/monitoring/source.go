@@ -0,0 +1,63 @@
+package monitoring
+
+import (
+	"biocad-opcua/shared"
+	"fmt"
+)
+
+// The monitor of the OPC UA server is the source supporting all the features.
+var (
+	_ shared.Source          = (*OpcuaMonitor)(nil)
+	_ shared.ParameterWriter = (*OpcuaMonitor)(nil)
+	_ shared.MethodCaller    = (*OpcuaMonitor)(nil)
+	_ shared.ReloadNotifier  = (*OpcuaMonitor)(nil)
+	_ shared.EventSource     = (*OpcuaMonitor)(nil)
+	_ shared.Backfiller      = (*OpcuaMonitor)(nil)
+	_ shared.Describer       = (*OpcuaMonitor)(nil)
+	_ shared.Diagnosable     = (*OpcuaMonitor)(nil)
+	_ shared.Recordable      = (*OpcuaMonitor)(nil)
+)
+
+// Health returns nil if the monitor is connected to the healthy server
+// or the reason it doesn't receive the values of the parameters.
+func (monitor *OpcuaMonitor) Health() error {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	if !monitor.connected {
+		return fmt.Errorf("Not connected to the server '%s'", monitor.config.Name)
+	}
+
+	if monitor.degraded {
+		return fmt.Errorf("The server %s is degraded", monitor.endpoint)
+	}
+
+	return nil
+}
+
+// neutral returns the part of the parameter common to all the protocols.
+func (parameter Parameter) neutral() shared.Parameter {
+	return shared.Parameter{
+		Name:     parameter.Name,
+		Address:  parameter.NodeID,
+		Writable: parameter.Writable,
+		Limits:   parameter.Limits,
+		Bounds:   parameter.Bounds,
+		Metadata: parameter.Metadata,
+		MaxAge:   parameter.MaxAge,
+	}
+}
+
+// withNeutral returns the parameter with the part common to all the protocols
+// replaced with the given one, so only the OPC UA settings are kept.
+func (parameter Parameter) withNeutral(neutral shared.Parameter) Parameter {
+	parameter.Name = neutral.Name
+	parameter.NodeID = neutral.Address
+	parameter.Writable = neutral.Writable
+	parameter.Limits = neutral.Limits
+	parameter.Bounds = neutral.Bounds
+	parameter.Metadata = neutral.Metadata
+	parameter.MaxAge = neutral.MaxAge
+
+	return parameter
+}
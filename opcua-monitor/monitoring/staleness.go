@@ -0,0 +1,174 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// staleCheckInterval is the interval of checking the parameters for staleness.
+const staleCheckInterval = time.Second
+
+// freshness tracks the updates of the parameter.
+type freshness struct {
+	// updated is the time the parameter was last updated at.
+	updated time.Time
+	// source is the source timestamp of the last value.
+	source time.Time
+	// probed is the time the parameter was last read to check if it's alive.
+	probed time.Time
+	stale  bool
+}
+
+// freshnessOf returns the update tracking of the parameter with the given client handle.
+// The parameter is considered updated when it's seen for the first time.
+func (monitor *OpcuaMonitor) freshnessOf(handle uint32) *freshness {
+	state, ok := monitor.freshness[handle]
+
+	if !ok {
+		state = &freshness{updated: time.Now()}
+		monitor.freshness[handle] = state
+	}
+
+	return state
+}
+
+// markUpdated records the value of the parameter received from the server.
+// The parameter is updated if the value has the new source timestamp or,
+// if the parameter is probed, the value has the good quality.
+func (monitor *OpcuaMonitor) markUpdated(handle uint32, sample data.Sample) {
+	parameter := monitor.parameters[handle]
+	state := monitor.freshnessOf(handle)
+
+	// The timestamp isn't changed if the value has been re-read.
+	updated := sample.SourceTimestamp.IsZero() || !sample.SourceTimestamp.Equal(state.source) ||
+		(parameter.Probe && !sample.Quality.IsBad())
+
+	if !updated {
+		return
+	}
+
+	state.updated = time.Now()
+	state.source = sample.SourceTimestamp
+
+	if state.stale {
+		state.stale = false
+		monitor.sendStalenessEvent(parameter, state)
+	}
+}
+
+// checkStaleness marks the parameters which haven't been updated
+// for longer than their maximum age as stale. The probed parameters
+// are read from the server first to check if they're alive.
+func (monitor *OpcuaMonitor) checkStaleness() {
+	monitor.probeParameters()
+
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	now := time.Now()
+
+	for handle, parameter := range monitor.parameters {
+		state := monitor.freshnessOf(handle)
+
+		if parameter.MaxAge == 0 || state.stale || now.Sub(state.updated) <= parameter.MaxAge {
+			continue
+		}
+
+		state.stale = true
+		monitor.sendStalenessEvent(parameter, state)
+	}
+}
+
+// probeParameters reads the probed parameters which haven't been updated
+// or read for longer than their maximum age. The parameters are read anyway
+// in the polling mode, so they aren't probed.
+func (monitor *OpcuaMonitor) probeParameters() {
+	if monitor.config.polling() {
+		return
+	}
+
+	monitor.synchronizer.Lock()
+	connection := monitor.connection
+	connected := monitor.connected
+	now := time.Now()
+	handles := make([]uint32, 0)
+	nodes := make([]*ua.ReadValueID, 0)
+
+	for handle, parameter := range monitor.parameters {
+		state := monitor.freshnessOf(handle)
+
+		if !parameter.Probe || parameter.MaxAge == 0 ||
+			now.Sub(state.updated) <= parameter.MaxAge || now.Sub(state.probed) <= parameter.MaxAge {
+			continue
+		}
+
+		id, err := resolveNodeID(parameter.NodeID, monitor.namespaces)
+
+		if err != nil {
+			continue
+		}
+
+		state.probed = now
+		handles = append(handles, handle)
+		nodes = append(nodes, &ua.ReadValueID{
+			NodeID:       id,
+			AttributeID:  ua.AttributeIDValue,
+			DataEncoding: &ua.QualifiedName{},
+		})
+	}
+	monitor.synchronizer.Unlock()
+
+	if !connected || len(nodes) == 0 {
+		return
+	}
+
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead:        nodes,
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+	})
+
+	// The parameters become stale if they can't be read.
+	if err != nil || len(res.Results) != len(nodes) {
+		monitor.logger.Println("Couldn't probe the parameters:", err)
+		return
+	}
+
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	for i, value := range res.Results {
+		// The parameter may have been removed while it was being read.
+		if _, ok := monitor.parameters[handles[i]]; !ok {
+			continue
+		}
+
+		sample, err := sampleFromDataValue(value)
+
+		if err != nil {
+			continue
+		}
+
+		monitor.markUpdated(handles[i], sample)
+	}
+}
+
+// sendStalenessEvent notifies the subscribers that the parameter has become stale or fresh.
+func (monitor *OpcuaMonitor) sendStalenessEvent(parameter Parameter, state *freshness) {
+	if state.stale {
+		monitor.logger.Printf("The parameter '%s' of the server '%s' is stale: no updates since %v",
+			parameter.Name, monitor.config.Name, state.updated)
+	} else {
+		monitor.logger.Printf("The parameter '%s' of the server '%s' is updated again",
+			parameter.Name, monitor.config.Name)
+	}
+
+	monitor.fanout.SendMeasurement(data.StalenessEvent{
+		Server:     monitor.config.Name,
+		Parameter:  parameter.Name,
+		Stale:      state.stale,
+		LastUpdate: state.updated,
+		Timestamp:  time.Now(),
+	})
+}
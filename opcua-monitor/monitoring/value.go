@@ -0,0 +1,144 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// valueFromVariant converts the OPC UA variant to the typed parameter value.
+func valueFromVariant(variant *ua.Variant) (data.Value, error) {
+	if variant == nil {
+		return data.Value{}, fmt.Errorf("The variant is empty")
+	}
+
+	value, err := convertValue(variant.Value())
+
+	if err != nil {
+		return data.Value{}, err
+	}
+
+	value.Type = strings.TrimPrefix(variant.Type().String(), "TypeID")
+
+	return value, nil
+}
+
+// convertValue converts the decoded variant contents to the typed parameter value.
+func convertValue(raw interface{}) (data.Value, error) {
+	switch v := raw.(type) {
+	case bool:
+		return data.NewBoolean(v), nil
+
+	case int8:
+		return data.NewNumber(float64(v)), nil
+
+	case uint8:
+		return data.NewNumber(float64(v)), nil
+
+	case int16:
+		return data.NewNumber(float64(v)), nil
+
+	case uint16:
+		return data.NewNumber(float64(v)), nil
+
+	case int32:
+		return data.NewNumber(float64(v)), nil
+
+	case uint32:
+		return data.NewNumber(float64(v)), nil
+
+	case int64:
+		return data.NewNumber(float64(v)), nil
+
+	case uint64:
+		return data.NewNumber(float64(v)), nil
+
+	case float32:
+		return data.NewNumber(float64(v)), nil
+
+	case float64:
+		return data.NewNumber(v), nil
+
+	case string:
+		return data.NewText(v), nil
+
+	case time.Time:
+		return data.NewText(v.Format(time.RFC3339Nano)), nil
+
+	case *ua.LocalizedText:
+		return data.NewText(v.Text), nil
+
+	case *ua.QualifiedName:
+		return data.NewText(v.Name), nil
+
+	case *ua.NodeID:
+		return data.NewText(v.String()), nil
+
+	case *ua.GUID:
+		return data.NewText(v.String()), nil
+
+	case ua.StatusCode:
+		return data.NewNumber(float64(v)), nil
+
+	case []byte:
+		return data.NewText(fmt.Sprintf("%x", v)), nil
+	}
+
+	// Arrays of any of the types above.
+	rv := reflect.ValueOf(raw)
+
+	if rv.Kind() == reflect.Slice {
+		items := make([]data.Value, rv.Len())
+
+		for i := 0; i < rv.Len(); i++ {
+			item, err := convertValue(rv.Index(i).Interface())
+
+			if err != nil {
+				return data.Value{}, err
+			}
+
+			items[i] = item
+		}
+
+		return data.NewArray(items), nil
+	}
+
+	return data.Value{}, fmt.Errorf("Unsupported value type %T", raw)
+}
+
+// sampleFromDataValue converts the OPC UA data value to the parameter sample.
+func sampleFromDataValue(dataValue *ua.DataValue) (data.Sample, error) {
+	sample := data.Sample{
+		Quality:         data.NewQuality(uint32(dataValue.Status), statusName(dataValue.Status)),
+		SourceTimestamp: dataValue.SourceTimestamp,
+		ServerTimestamp: dataValue.ServerTimestamp,
+	}
+
+	// Values of bad quality may come without the variant.
+	if dataValue.Value == nil {
+		return sample, nil
+	}
+
+	value, err := valueFromVariant(dataValue.Value)
+
+	if err != nil {
+		return data.Sample{}, err
+	}
+
+	sample.Value = value
+
+	return sample, nil
+}
+
+// statusName returns the name of the status code ignoring its info bits.
+func statusName(status ua.StatusCode) string {
+	if description, ok := ua.StatusCodes[status&0xFFFF0000]; ok {
+		return strings.TrimPrefix(description.Name, "Status")
+	}
+
+	return fmt.Sprintf("0x%08X", uint32(status))
+}
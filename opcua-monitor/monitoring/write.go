@@ -0,0 +1,187 @@
+package monitoring
+
+import (
+	"biocad-opcua/data"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+// HasParameter returns true if the monitor monitors the parameter with the name.
+func (monitor *OpcuaMonitor) HasParameter(name string) bool {
+	monitor.synchronizer.Lock()
+	defer monitor.synchronizer.Unlock()
+
+	_, ok := monitor.parameterByName(name)
+
+	return ok
+}
+
+// WriteParameter writes the value to the parameter on the server converting
+// it to the data type of the node. Each write is recorded in the audit entry
+// sent to the subscribers along with its status, including the writes rejected
+// by the monitor (e.g. the parameter isn't writable or the value can't be converted).
+func (monitor *OpcuaMonitor) WriteParameter(command data.WriteCommand) data.CommandResult {
+	// The server is requested without the lock, so the notifications aren't held up by the write.
+	monitor.synchronizer.Lock()
+	parameter, ok := monitor.parameterByName(command.Parameter)
+	connected := monitor.connected
+	connection := monitor.connection
+	namespaces := monitor.namespaces
+	monitor.synchronizer.Unlock()
+
+	if !ok {
+		return monitor.rejectWrite(command, data.Value{},
+			fmt.Errorf("The parameter '%s' is not monitored", command.Parameter))
+	}
+
+	if !parameter.Writable {
+		return monitor.rejectWrite(command, data.Value{}, fmt.Errorf("The parameter '%s' is not writable", parameter.Name))
+	}
+
+	if number, ok := command.Value.Float(); ok && parameter.Limits != nil {
+		if number < parameter.Limits.LowerBound || number > parameter.Limits.UpperBound {
+			return monitor.rejectWrite(command, data.Value{}, fmt.Errorf("The value %v is out of the limits [%v, %v]",
+				number, parameter.Limits.LowerBound, parameter.Limits.UpperBound))
+		}
+	}
+
+	if !connected {
+		return monitor.rejectWrite(command, data.Value{}, fmt.Errorf("The server is not connected"))
+	}
+
+	id, err := resolveNodeID(parameter.NodeID, namespaces)
+
+	if err != nil {
+		return monitor.rejectWrite(command, data.Value{}, err)
+	}
+
+	// Read the data type to convert the value to and the value being replaced.
+	dataType, oldValue, err := readForWrite(connection, id)
+
+	if err != nil {
+		return monitor.rejectWrite(command, data.Value{}, err)
+	}
+
+	variant, err := variantFromValue(command.Value, dataType)
+
+	if err != nil {
+		return monitor.rejectWrite(command, oldValue, err)
+	}
+
+	res, err := connection.Write(&ua.WriteRequest{
+		NodesToWrite: []*ua.WriteValue{
+			{
+				NodeID:      id,
+				AttributeID: ua.AttributeIDValue,
+				Value: &ua.DataValue{
+					EncodingMask: ua.DataValueValue,
+					Value:        variant,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		return monitor.rejectWrite(command, oldValue, err)
+	}
+
+	if len(res.Results) == 0 {
+		return monitor.rejectWrite(command, oldValue, fmt.Errorf("The server returned no result"))
+	}
+
+	status := res.Results[0]
+	result := data.CommandResult{
+		StatusCode: uint32(status),
+		Status:     statusName(status),
+	}
+
+	monitor.logger.Printf("User '%s' wrote %v to the parameter '%s': %s",
+		command.User, command.Value.Interface(), parameter.Name, result.Status)
+
+	monitor.auditWrite(command, oldValue, result)
+
+	return result
+}
+
+// readForWrite reads the data type and the current value of the node.
+func readForWrite(connection *opcua.Client, id *ua.NodeID) (*ua.NodeID, data.Value, error) {
+	res, err := connection.Read(&ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{NodeID: id, AttributeID: ua.AttributeIDDataType},
+			{NodeID: id, AttributeID: ua.AttributeIDValue},
+		},
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	})
+
+	if err != nil {
+		return nil, data.Value{}, err
+	}
+
+	if len(res.Results) < 2 {
+		return nil, data.Value{}, fmt.Errorf("The server returned %d results instead of 2", len(res.Results))
+	}
+
+	if status := res.Results[0].Status; status != ua.StatusOK {
+		return nil, data.Value{}, fmt.Errorf("Couldn't read the data type: %s", statusName(status))
+	}
+
+	var dataType *ua.NodeID
+
+	if variant := res.Results[0].Value; variant != nil {
+		dataType, _ = variant.Value().(*ua.NodeID)
+	}
+
+	if dataType == nil {
+		return nil, data.Value{}, fmt.Errorf("The server returned an invalid data type")
+	}
+
+	// The old value is only recorded in the audit, so it's not required.
+	oldValue, _ := valueFromVariant(res.Results[1].Value)
+
+	return dataType, oldValue, nil
+}
+
+// rejectWrite logs and audits the reason the write has been rejected and returns it as the result.
+// The old value is empty if the write has been rejected before reading it.
+func (monitor *OpcuaMonitor) rejectWrite(command data.WriteCommand, oldValue data.Value, err error) data.CommandResult {
+	monitor.logger.Printf("Couldn't write %v to the parameter '%s' for the user '%s': %s",
+		command.Value.Interface(), command.Parameter, command.User, err)
+
+	result := data.CommandResult{
+		StatusCode: uint32(ua.StatusBad),
+		Status:     statusName(ua.StatusBad),
+		Error:      err.Error(),
+	}
+
+	monitor.auditWrite(command, oldValue, result)
+
+	return result
+}
+
+// auditWrite sends the audit entry of the write to the subscribers.
+func (monitor *OpcuaMonitor) auditWrite(command data.WriteCommand, oldValue data.Value, result data.CommandResult) {
+	monitor.fanout.SendMeasurement(data.WriteAudit{
+		Server:    monitor.config.Name,
+		Parameter: command.Parameter,
+		User:      command.User,
+		OldValue:  oldValue,
+		NewValue:  command.Value,
+		Status:    result.Status,
+		Error:     result.Error,
+		Timestamp: time.Now(),
+	})
+}
+
+// parameterByName returns the monitored parameter with the name.
+func (monitor *OpcuaMonitor) parameterByName(name string) (Parameter, bool) {
+	for _, parameter := range monitor.parameters {
+		if parameter.Name == name {
+			return parameter, true
+		}
+	}
+
+	return Parameter{}, false
+}
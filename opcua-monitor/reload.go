@@ -0,0 +1,136 @@
+package main
+
+import (
+	"biocad-opcua/opcua-monitor/monitoring"
+	"biocad-opcua/shared"
+	"log"
+	"os"
+	"time"
+)
+
+// addParameterToCache adds the parameter to the set of the parameters of the server
+// in the cache along with its bounds and metadata from the definition file.
+func addParameterToCache(cache *shared.Cache, server string, parameter shared.Parameter) error {
+	// Check if the parameter exists in the cache.
+	exists, err := cache.CheckParameterExists(server, parameter.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		err = cache.AddParameters(server, parameter.Name)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// The bounds from the definition file don't override the ones set by the operator.
+	if parameter.Bounds != nil {
+		_, err = cache.SetInitialParameterBounds(server, parameter.Name, *parameter.Bounds)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if parameter.Metadata.IsEmpty() {
+		return nil
+	}
+
+	// The units and the ranges read from the server are kept.
+	current, err := cache.GetParameterMetadata(server, parameter.Name)
+
+	if err != nil {
+		return err
+	}
+
+	return cache.SetParameterMetadata(server, parameter.Name, parameter.Metadata.Merge(current))
+}
+
+// reloadParameters brings the monitored parameters of all the servers in line with
+// their parameter sources and updates the set of the parameters in the cache.
+func reloadParameters(sources []shared.Source, cache *shared.Cache, logger *log.Logger) {
+	for _, source := range sources {
+		changes, err := source.ReloadParameters()
+
+		if err != nil {
+			logger.Printf("Couldn't reload the parameters of the server '%s': %s", source.Name(), err)
+			continue
+		}
+
+		if changes.IsEmpty() {
+			continue
+		}
+
+		for _, parameters := range [][]shared.Parameter{changes.Added, changes.Updated} {
+			for _, parameter := range parameters {
+				err = addParameterToCache(cache, source.Name(), parameter)
+				handleReloadError(logger, parameter, err)
+			}
+		}
+
+		for _, parameter := range changes.Removed {
+			err = cache.RemoveParameters(source.Name(), parameter.Name)
+			handleReloadError(logger, parameter, err)
+		}
+
+		logger.Printf("Reloaded the parameters of the server '%s': %d added, %d updated, %d removed",
+			source.Name(), len(changes.Added), len(changes.Updated), len(changes.Removed))
+	}
+}
+
+// watchParameterFiles checks the parameter files of the servers at the interval
+// and notifies the channel when any of them has been modified.
+func watchParameterFiles(configs []monitoring.ConnectionConfig, interval time.Duration, changes chan<- interface{}) {
+	modified := make(map[string]time.Time)
+
+	for _, config := range configs {
+		if path := config.Parameters.File; path != "" {
+			modified[path] = fileModificationTime(path)
+		}
+	}
+
+	if len(modified) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		changed := false
+
+		for path, last := range modified {
+			// The file may be missing for a while when it's being replaced.
+			current := fileModificationTime(path)
+
+			if !current.IsZero() && !current.Equal(last) {
+				modified[path] = current
+				changed = true
+			}
+		}
+
+		if changed {
+			changes <- true
+		}
+	}
+}
+
+// fileModificationTime returns the time the file was modified at or zero if it doesn't exist.
+func fileModificationTime(path string) time.Time {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+func handleReloadError(logger *log.Logger, parameter shared.Parameter, err error) {
+	if err != nil {
+		logger.Printf("Couldn't update the parameter '%s' in the cache: %s", parameter.Name, err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"biocad-opcua/opcua-monitor/monitoring"
+	"biocad-opcua/shared"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+)
+
+// startRecording makes each source supporting the recording write the data
+// it receives to the new recording file in the directory. It returns the recorders to close.
+func startRecording(sources []shared.Source, dir string, logger *log.Logger) ([]io.Closer, error) {
+	recorders := make([]io.Closer, 0, len(sources))
+	started := time.Now().Format("20060102-150405")
+
+	for _, source := range sources {
+		recordable, ok := source.(shared.Recordable)
+
+		if !ok {
+			continue
+		}
+
+		filePath := filepath.Join(dir, fmt.Sprintf("%s-%s.rec", source.Name(), started))
+		recorder, err := recordable.StartRecording(filePath)
+
+		if err != nil {
+			closeRecorders(recorders, logger)
+			return nil, err
+		}
+
+		logger.Printf("Recording the notifications of the server '%s' to %s", source.Name(), filePath)
+		recorders = append(recorders, recorder)
+	}
+
+	return recorders, nil
+}
+
+// closeRecorders flushes the recordings.
+func closeRecorders(recorders []io.Closer, logger *log.Logger) {
+	for _, recorder := range recorders {
+		err := recorder.Close()
+
+		if err != nil {
+			logger.Println("Couldn't close the recording:", err)
+		}
+	}
+}
+
+// replayRecording sends the recorded notifications to the database and the other services
+// instead of the ones received from the server, then waits for the interrupt.
+func replayRecording(ctx context.Context, logger *log.Logger) error {
+	recording, err := monitoring.OpenRecording(replayPath)
+
+	if err != nil {
+		return err
+	}
+	defer recording.Close()
+
+	logger.Printf("Replaying the notifications of the server '%s' recorded at %v with the speed %v",
+		recording.Server(), recording.Started(), replaySpeed)
+
+	// The monitor isn't connected, it only converts the notifications to the measurements.
+	config := monitoring.DefaultConnectionConfig()
+	config.Name = recording.Server()
+	config.Endpoint = replayPath
+	monitor := monitoring.NewOpcuaMonitor(ctx, config, logger, 0)
+
+	// Create a database client and connect to the database.
+	dbclient := shared.NewDbClient(dbAddress, database, logger, capacity)
+	err = dbclient.Connect()
+
+	if err != nil {
+		return err
+	}
+	defer dbclient.CloseConnection()
+
+	// Create a publisher to spread measures across the application.
+	pb := shared.NewPublisher(brokerAddress, topic, logger)
+	err = pb.Connect()
+
+	if err != nil {
+		return err
+	}
+	defer pb.CloseConnection()
+
+	dbchannel := dbclient.GetSubscriptionChannel()
+	dbclient.Start()
+	defer dbclient.Stop()
+
+	pbchannel := pb.GetChannel()
+	pb.Start()
+	defer pb.Stop()
+
+	monitor.AddSubscriber(dbchannel)
+	monitor.AddSubscriber(pbchannel)
+
+	err = monitor.Replay(recording, replaySpeed)
+
+	if err != nil {
+		return err
+	}
+
+	// The measurements are delivered to the subscribers asynchronously.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Kill, os.Interrupt)
+
+	logger.Println("The replay is over, waiting for the interrupt")
+	<-interrupt
+
+	return nil
+}
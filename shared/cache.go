@@ -2,9 +2,11 @@ package shared
 
 import (
 	"biocad-opcua/data"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 
 	"github.com/go-redis/redis"
 )
@@ -30,9 +32,9 @@ func (cache *Cache) CloseConnection() {
 	cache.client.Close()
 }
 
-// CheckParameterBoundsExist checks if the parameter bounds exist in the cache.
-func (cache *Cache) CheckParameterBoundsExist(parameter string) (bool, error) {
-	result, err := cache.client.Exists(parameter).Result()
+// CheckParameterBoundsExist checks if the bounds of the parameter of the server exist in the cache.
+func (cache *Cache) CheckParameterBoundsExist(server, parameter string) (bool, error) {
+	result, err := cache.client.Exists(boundsKey(server, parameter)).Result()
 	cache.handleCheckParameterBoundsExistError(err)
 
 	if err != nil {
@@ -46,9 +48,9 @@ func (cache *Cache) CheckParameterBoundsExist(parameter string) (bool, error) {
 	return false, nil
 }
 
-// CheckParameterExists checks if the parameter exists in the cache.
-func (cache *Cache) CheckParameterExists(parameter string) (bool, error) {
-	exists, err := cache.client.SIsMember("parameters", parameter).Result()
+// CheckParameterExists checks if the parameter of the server exists in the cache.
+func (cache *Cache) CheckParameterExists(server, parameter string) (bool, error) {
+	exists, err := cache.client.SIsMember(parametersKey(server), parameter).Result()
 	cache.handleCheckParameterExistsError(err)
 
 	if err != nil {
@@ -62,37 +64,74 @@ func (cache *Cache) CheckParameterExists(parameter string) (bool, error) {
 	return false, nil
 }
 
-// AddParameters adds new parameter names to the cache.
-func (cache *Cache) AddParameters(parameters ...string) error {
+// AddParameters adds new parameter names of the server to the cache.
+func (cache *Cache) AddParameters(server string, parameters ...string) error {
 	values := make([]interface{}, len(parameters))
 
 	for i := range parameters {
 		values[i] = parameters[i]
 	}
 
-	// If the parameter doesn't exist, add it to the set.
-	err := cache.client.SAdd("parameters", values...).Err()
+	// If the parameter doesn't exist, add it to the set of the server.
+	_, err := cache.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(serversKey, server)
+		pipe.SAdd(parametersKey(server), values...)
+
+		return nil
+	})
 	cache.handleAddParameterError(err)
 
 	return err
 }
 
-// GetAllParameters returns a list of the all parameters from the cache.
-func (cache *Cache) GetAllParameters() ([]string, error) {
-	parameters, err := cache.client.SMembers("parameters").Result()
+// RemoveParameters removes the parameter names of the server from the cache.
+// The bounds and the metadata of the parameters are kept in case they're added again.
+func (cache *Cache) RemoveParameters(server string, parameters ...string) error {
+	values := make([]interface{}, len(parameters))
+
+	for i := range parameters {
+		values[i] = parameters[i]
+	}
+
+	err := cache.client.SRem(parametersKey(server), values...).Err()
+	cache.handleRemoveParameterError(err)
+
+	return err
+}
+
+// GetAllParameters returns the lists of the parameters of all the servers
+// from the cache by the server names.
+func (cache *Cache) GetAllParameters() (map[string][]string, error) {
+	servers, err := cache.client.SMembers(serversKey).Result()
 	cache.handleGetAllParametersError(err)
 
 	if err != nil {
 		return nil, err
 	}
 
+	parameters := make(map[string][]string, len(servers))
+
+	for _, server := range servers {
+		names, err := cache.client.SMembers(parametersKey(server)).Result()
+		cache.handleGetAllParametersError(err)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// The servers whose parameters have all been removed are omitted.
+		if len(names) > 0 {
+			parameters[server] = names
+		}
+	}
+
 	return parameters, nil
 }
 
-// GetParameterBounds returns alert thresholds for the parameter.
-func (cache *Cache) GetParameterBounds(parameter string) (data.Bounds, error) {
+// GetParameterBounds returns alert thresholds for the parameter of the server.
+func (cache *Cache) GetParameterBounds(server, parameter string) (data.Bounds, error) {
 	bounds := data.Bounds{}
-	fields, err := cache.client.HGetAll(parameter).Result()
+	fields, err := cache.client.HGetAll(boundsKey(server, parameter)).Result()
 	cache.handleGetParameterBoundsError(err)
 
 	if err != nil {
@@ -143,15 +182,13 @@ func (cache *Cache) GetParameterBounds(parameter string) (data.Bounds, error) {
 	return bounds, nil
 }
 
-// SetParameterBounds assigns new alert thresholds to the parameter.
-func (cache *Cache) SetParameterBounds(parameter string, bounds data.Bounds) error {
-	fields := map[string]interface{}{
-		"lower_bound": bounds.LowerBound,
-		"upper_bound": bounds.UpperBound,
-	}
+// SetParameterBounds assigns new alert thresholds to the parameter of the server.
+// The thresholds are marked as assigned, so the initial ones don't replace them.
+func (cache *Cache) SetParameterBounds(server, parameter string, bounds data.Bounds) error {
+	fields := boundsFields(bounds, true)
 
 	// Change the bounds for the parameter.
-	err := cache.client.HMSet(parameter, fields).Err()
+	err := cache.client.HMSet(boundsKey(server, parameter), fields).Err()
 	cache.handleSetParameterBoundsError(err)
 
 	if err != nil {
@@ -159,7 +196,369 @@ func (cache *Cache) SetParameterBounds(parameter string, bounds data.Bounds) err
 	}
 
 	// If the parameter doesn't exist, add it to the set.
-	return cache.AddParameters(parameter)
+	return cache.AddParameters(server, parameter)
+}
+
+// SetDefaultParameterBounds assigns the default alerting thresholds to the parameter
+// of the server unless it has any thresholds. The default thresholds aren't marked
+// as assigned, so the initial ones replace them. It returns true if they have been set.
+func (cache *Cache) SetDefaultParameterBounds(server, parameter string) (bool, error) {
+	return cache.setBoundsUnless(server, parameter, data.DefaultBounds(), false, func(fields map[string]string) bool {
+		return len(fields) > 0
+	})
+}
+
+// GetParameterMetadata returns the description of the parameter of the server.
+// The metadata is empty if the parameter hasn't been described.
+func (cache *Cache) GetParameterMetadata(server, parameter string) (data.ParameterMetadata, error) {
+	fields, err := cache.client.HGetAll(metadataKey(server, parameter)).Result()
+	cache.handleGetParameterMetadataError(err)
+
+	if err != nil {
+		return data.ParameterMetadata{}, err
+	}
+
+	metadata := data.ParameterMetadata{
+		Unit:        fields["unit"],
+		Description: fields["description"],
+		DataType:    fields["data_type"],
+	}
+
+	metadata.EURange, err = rangeFromFields(fields, "eu_range")
+	cache.handleParameterValueCastError(err)
+
+	if err != nil {
+		return data.ParameterMetadata{}, err
+	}
+
+	metadata.InstrumentRange, err = rangeFromFields(fields, "instrument_range")
+	cache.handleParameterValueCastError(err)
+
+	if err != nil {
+		return data.ParameterMetadata{}, err
+	}
+
+	return metadata, nil
+}
+
+// SetParameterMetadata assigns the new description to the parameter of the server.
+func (cache *Cache) SetParameterMetadata(server, parameter string, metadata data.ParameterMetadata) error {
+	fields := map[string]interface{}{
+		"unit":        metadata.Unit,
+		"description": metadata.Description,
+		"data_type":   metadata.DataType,
+	}
+
+	if metadata.EURange != nil {
+		fields["eu_range_low"] = metadata.EURange.LowerBound
+		fields["eu_range_high"] = metadata.EURange.UpperBound
+	}
+
+	if metadata.InstrumentRange != nil {
+		fields["instrument_range_low"] = metadata.InstrumentRange.LowerBound
+		fields["instrument_range_high"] = metadata.InstrumentRange.UpperBound
+	}
+
+	// Replace the whole description, so the ranges which are gone don't remain.
+	_, err := cache.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(metadataKey(server, parameter))
+		pipe.HMSet(metadataKey(server, parameter), fields)
+
+		return nil
+	})
+	cache.handleSetParameterMetadataError(err)
+
+	return err
+}
+
+// SetInitialParameterBounds assigns the alerting thresholds to the parameter of the server
+// unless they have been assigned already, e.g. by the operator. The default thresholds
+// are replaced. It returns true if the thresholds have been set.
+func (cache *Cache) SetInitialParameterBounds(server, parameter string, bounds data.Bounds) (bool, error) {
+	return cache.setBoundsUnless(server, parameter, bounds, true, boundsAssigned)
+}
+
+// setBoundsUnless assigns the alerting thresholds to the parameter of the server unless
+// the current fields of the thresholds are kept. The fields are checked and replaced
+// in the transaction, so the thresholds assigned meanwhile aren't lost.
+// It returns true if the thresholds have been set.
+func (cache *Cache) setBoundsUnless(server, parameter string, bounds data.Bounds, assigned bool,
+	keep func(fields map[string]string) bool) (bool, error) {
+	key := boundsKey(server, parameter)
+	set := false
+	transaction := func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(key).Result()
+
+		if err != nil || keep(fields) {
+			return err
+		}
+
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.HMSet(key, boundsFields(bounds, assigned))
+			pipe.SAdd(serversKey, server)
+			pipe.SAdd(parametersKey(server), parameter)
+
+			return nil
+		})
+		set = err == nil
+
+		return err
+	}
+
+	var err error
+
+	// The transaction fails if the thresholds have been changed meanwhile, so it's repeated.
+	for attempt := 0; attempt < maxBoundsAttempts; attempt++ {
+		err = cache.client.Watch(transaction, key)
+
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+
+	cache.handleSetParameterBoundsError(err)
+
+	return set, err
+}
+
+// boundsFields returns the fields of the hash of the alerting thresholds.
+func boundsFields(bounds data.Bounds, assigned bool) map[string]interface{} {
+	return map[string]interface{}{
+		"lower_bound":       bounds.LowerBound,
+		"upper_bound":       bounds.UpperBound,
+		boundsAssignedField: assigned,
+	}
+}
+
+// boundsAssigned returns true if the fields of the hash are the alerting thresholds
+// assigned explicitly rather than the default ones. The thresholds stored before they were
+// marked are considered assigned unless they're the default ones.
+func boundsAssigned(fields map[string]string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+
+	if assigned, ok := fields[boundsAssignedField]; ok {
+		value, err := strconv.ParseBool(assigned)
+
+		return err == nil && value
+	}
+
+	defaults := data.DefaultBounds()
+
+	return fields["lower_bound"] != strconv.FormatFloat(defaults.LowerBound, 'f', -1, 64) ||
+		fields["upper_bound"] != strconv.FormatFloat(defaults.UpperBound, 'f', -1, 64)
+}
+
+// MigrateLegacyKeys moves the parameters and their bounds stored before the cache
+// has been shared by several servers to the keys of the server. The legacy keys are removed,
+// so it's done once, and the bounds already stored for the server are kept.
+// It returns the number of the moved parameters.
+func (cache *Cache) MigrateLegacyKeys(server string) (int, error) {
+	moved := 0
+
+	// The transaction fails if another monitor has migrated the keys meanwhile.
+	err := cache.client.Watch(func(tx *redis.Tx) error {
+		parameters, err := tx.SMembers(legacyParametersKey).Result()
+
+		if err != nil || len(parameters) == 0 {
+			return err
+		}
+
+		bounds := make(map[string]map[string]interface{})
+
+		for _, parameter := range legacyBoundsKeys(parameters) {
+			fields, err := tx.HGetAll(parameter).Result()
+
+			if err != nil {
+				return err
+			}
+
+			exists, err := tx.Exists(boundsKey(server, parameter)).Result()
+
+			if err != nil {
+				return err
+			}
+
+			if len(fields) == 0 || exists > 0 {
+				continue
+			}
+
+			bounds[parameter] = make(map[string]interface{}, len(fields))
+
+			for name, value := range fields {
+				bounds[parameter][name] = value
+			}
+		}
+
+		values := make([]interface{}, len(parameters))
+
+		for i := range parameters {
+			values[i] = parameters[i]
+		}
+
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.SAdd(serversKey, server)
+			pipe.SAdd(parametersKey(server), values...)
+
+			for parameter, fields := range bounds {
+				pipe.HMSet(boundsKey(server, parameter), fields)
+			}
+
+			for _, parameter := range legacyBoundsKeys(parameters) {
+				pipe.Del(parameter)
+			}
+
+			pipe.Del(legacyParametersKey)
+
+			return nil
+		})
+
+		if err == nil {
+			moved = len(parameters)
+		}
+
+		return err
+	}, legacyParametersKey)
+
+	if err == redis.TxFailedErr {
+		err = nil
+	}
+
+	cache.handleMigrateLegacyKeysError(err)
+
+	return moved, err
+}
+
+// legacyBoundsKeys returns the names of the legacy parameters whose bounds were stored
+// under their names. The names which are the keys of the cache now are skipped,
+// since the bounds of such parameters couldn't have been told from those keys.
+func legacyBoundsKeys(parameters []string) []string {
+	keys := make([]string, 0, len(parameters))
+
+	for _, parameter := range parameters {
+		switch {
+		case parameter == legacyParametersKey, parameter == serversKey, parameter == diagnosticsKey:
+			continue
+
+		case strings.HasPrefix(parameter, "parameters:"), strings.HasPrefix(parameter, "bounds:"),
+			strings.HasPrefix(parameter, "metadata:"):
+			continue
+		}
+
+		keys = append(keys, parameter)
+	}
+
+	return keys
+}
+
+// rangeFromFields returns the range stored in the '<prefix>_low' and '<prefix>_high' fields
+// of the hash or nil if it isn't stored.
+func rangeFromFields(fields map[string]string, prefix string) (*data.Bounds, error) {
+	low, ok := fields[prefix+"_low"]
+
+	if !ok {
+		return nil, nil
+	}
+
+	high, ok := fields[prefix+"_high"]
+
+	if !ok {
+		return nil, nil
+	}
+
+	lowerBound, err := strconv.ParseFloat(low, 64)
+
+	if err != nil {
+		return nil, err
+	}
+
+	upperBound, err := strconv.ParseFloat(high, 64)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.Bounds{LowerBound: lowerBound, UpperBound: upperBound}, nil
+}
+
+// SetServerDiagnostics replaces the latest diagnostics of the server.
+func (cache *Cache) SetServerDiagnostics(diagnostics data.ServerDiagnostics) error {
+	value, err := json.Marshal(diagnostics)
+
+	if err != nil {
+		return err
+	}
+
+	err = cache.client.HSet(diagnosticsKey, diagnostics.Server, value).Err()
+	cache.handleSetServerDiagnosticsError(err)
+
+	return err
+}
+
+// GetAllServerDiagnostics returns the latest diagnostics of all the servers by the server names.
+func (cache *Cache) GetAllServerDiagnostics() (map[string]data.ServerDiagnostics, error) {
+	fields, err := cache.client.HGetAll(diagnosticsKey).Result()
+	cache.handleGetServerDiagnosticsError(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make(map[string]data.ServerDiagnostics, len(fields))
+
+	for server, value := range fields {
+		var diagnostics data.ServerDiagnostics
+		err = json.Unmarshal([]byte(value), &diagnostics)
+		cache.handleGetServerDiagnosticsError(err)
+
+		if err != nil {
+			return nil, err
+		}
+
+		servers[server] = diagnostics
+	}
+
+	return servers, nil
+}
+
+// Keys of the cache shared by all the servers.
+const (
+	// diagnosticsKey is the key of the hash of the latest server diagnostics.
+	diagnosticsKey = "diagnostics"
+	// serversKey is the key of the set of the servers having the parameters.
+	serversKey = "servers"
+	// legacyParametersKey is the key of the set of the parameters used before
+	// the cache has been shared by several servers. The bounds of the parameters
+	// were stored in the hashes whose keys were the names of the parameters.
+	legacyParametersKey = "parameters"
+)
+
+const (
+	// boundsAssignedField is the field of the hash of the alerting thresholds
+	// telling if they've been assigned explicitly rather than by default.
+	boundsAssignedField = "assigned"
+	// maxBoundsAttempts limits the number of the attempts to set the thresholds
+	// changed by another client meanwhile.
+	maxBoundsAttempts = 3
+)
+
+// The parameters of different servers may have the same names,
+// so the keys of the parameters include the name of the server.
+
+// parametersKey returns the key of the set of the parameters of the server in the cache.
+func parametersKey(server string) string {
+	return "parameters:" + server
+}
+
+// boundsKey returns the key of the parameter alerting bounds in the cache.
+func boundsKey(server, parameter string) string {
+	return "bounds:" + server + ":" + parameter
+}
+
+// metadataKey returns the key of the parameter metadata in the cache.
+func metadataKey(server, parameter string) string {
+	return "metadata:" + server + ":" + parameter
 }
 
 // NewCache creates a new cache client.
@@ -176,6 +575,18 @@ func (cache *Cache) handleGetParameterBoundsError(err error) {
 	}
 }
 
+func (cache *Cache) handleGetParameterMetadataError(err error) {
+	if err != nil {
+		cache.logger.Println("Couldn't get metadata for the parameter:", err)
+	}
+}
+
+func (cache *Cache) handleSetParameterMetadataError(err error) {
+	if err != nil {
+		cache.logger.Println("Couldn't set parameter metadata in the cache:", err)
+	}
+}
+
 func (cache *Cache) handleParameterValueCastError(err error) {
 	if err != nil {
 		cache.logger.Println("The parameter value is incorrect:", err)
@@ -200,6 +611,12 @@ func (cache *Cache) handleAddParameterError(err error) {
 	}
 }
 
+func (cache *Cache) handleRemoveParameterError(err error) {
+	if err != nil {
+		cache.logger.Println("Couldn't remove the parameter from the cache:", err)
+	}
+}
+
 func (cache *Cache) handleCheckParameterExistsError(err error) {
 	if err != nil {
 		cache.logger.Println("Couldn't check if the parameter exists in the cache", err)
@@ -217,3 +634,21 @@ func (cache *Cache) handleSnapshotSaveError(err error) {
 		cache.logger.Println("Couldn't save the cache snapshot to the disk", err)
 	}
 }
+
+func (cache *Cache) handleMigrateLegacyKeysError(err error) {
+	if err != nil {
+		cache.logger.Println("Couldn't migrate the legacy keys of the parameters:", err)
+	}
+}
+
+func (cache *Cache) handleSetServerDiagnosticsError(err error) {
+	if err != nil {
+		cache.logger.Println("Couldn't set the server diagnostics in the cache:", err)
+	}
+}
+
+func (cache *Cache) handleGetServerDiagnosticsError(err error) {
+	if err != nil {
+		cache.logger.Println("Couldn't get the server diagnostics from the cache:", err)
+	}
+}
@@ -0,0 +1,56 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLegacyBoundsKeys(t *testing.T) {
+	tests := []struct {
+		parameters []string
+		want       []string
+	}{
+		{parameters: []string{}, want: []string{}},
+		{parameters: []string{"Temperature", "Pressure"}, want: []string{"Temperature", "Pressure"}},
+		{
+			parameters: []string{"Temperature", "parameters", "servers", "diagnostics"},
+			want:       []string{"Temperature"},
+		},
+		{
+			parameters: []string{"bounds:plc:Temperature", "metadata:plc:Temperature", "parameters:plc", "Pressure"},
+			want:       []string{"Pressure"},
+		},
+		// The names only look like the keys if they have the prefixes.
+		{parameters: []string{"Temperature:bounds"}, want: []string{"Temperature:bounds"}},
+	}
+
+	for _, test := range tests {
+		got := legacyBoundsKeys(test.parameters)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("legacyBoundsKeys(%q) = %q, want %q", test.parameters, got, test.want)
+		}
+	}
+}
+
+func TestBoundsAssigned(t *testing.T) {
+	tests := []struct {
+		fields map[string]string
+		want   bool
+	}{
+		{fields: map[string]string{}, want: false},
+		{fields: map[string]string{"lower_bound": "-1", "upper_bound": "1", "assigned": "0"}, want: false},
+		// The operator may assign the thresholds equal to the default ones.
+		{fields: map[string]string{"lower_bound": "-1", "upper_bound": "1", "assigned": "1"}, want: true},
+		{fields: map[string]string{"lower_bound": "30", "upper_bound": "40", "assigned": "1"}, want: true},
+		// The thresholds stored before they were marked.
+		{fields: map[string]string{"lower_bound": "-1", "upper_bound": "1"}, want: false},
+		{fields: map[string]string{"lower_bound": "30", "upper_bound": "40"}, want: true},
+	}
+
+	for _, test := range tests {
+		if got := boundsAssigned(test.fields); got != test.want {
+			t.Errorf("boundsAssigned(%v) = %t, want %t", test.fields, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package shared
+
+import (
+	"biocad-opcua/data"
+	"encoding/json"
+	"log"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// Subjects of the topic the commands are sent on.
+const (
+	writeSubject = "write"
+	callSubject  = "call"
+)
+
+// Commander sends commands to the monitor through the message broker and waits for the replies.
+type Commander struct {
+	address string
+	conn    *nats.Conn
+	logger  *log.Logger
+	topic   string
+	timeout time.Duration
+}
+
+// Connect establishes the connection with the message broker.
+func (commander *Commander) Connect() error {
+	conn, err := nats.Connect(commander.address)
+	commander.handleConnectionError(err)
+
+	if err != nil {
+		return err
+	}
+
+	commander.conn = conn
+
+	return err
+}
+
+// Write asks the monitor to write the value to the parameter on the OPC UA server.
+func (commander *Commander) Write(command data.WriteCommand) (data.CommandResult, error) {
+	var result data.CommandResult
+	err := commander.request(writeSubject, command, &result)
+
+	return result, err
+}
+
+// Call asks the monitor to call the method on the OPC UA server.
+func (commander *Commander) Call(command data.CallCommand) (data.CommandResult, error) {
+	var result data.CommandResult
+	err := commander.request(callSubject, command, &result)
+
+	return result, err
+}
+
+// request sends the command on the subject of the topic and decodes the reply to the result.
+func (commander *Commander) request(subject string, command, result interface{}) error {
+	bytes, err := json.Marshal(command)
+	commander.handleJSONMarshalError(err)
+
+	if err != nil {
+		return err
+	}
+
+	reply, err := commander.conn.Request(commander.topic+"."+subject, bytes, commander.timeout)
+	commander.handleRequestError(err)
+
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(reply.Data, result)
+	commander.handleJSONUnmarshalError(err)
+
+	return err
+}
+
+// CloseConnection closes the connection with the message broker.
+func (commander *Commander) CloseConnection() {
+	commander.conn.Close()
+}
+
+// NewCommander creates a new commander to send commands on the topic.
+func NewCommander(address, topic string, logger *log.Logger, timeout time.Duration) *Commander {
+	return &Commander{
+		address: address,
+		topic:   topic,
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+func (commander *Commander) handleConnectionError(err error) {
+	if err != nil {
+		commander.logger.Println("Couldn't connect to the NATS message broking service:", err)
+	}
+}
+
+func (commander *Commander) handleJSONMarshalError(err error) {
+	if err != nil {
+		commander.logger.Println("Couldn't serialize the command to JSON:", err)
+	}
+}
+
+func (commander *Commander) handleJSONUnmarshalError(err error) {
+	if err != nil {
+		commander.logger.Println("Couldn't deserialize the reply:", err)
+	}
+}
+
+func (commander *Commander) handleRequestError(err error) {
+	if err != nil {
+		commander.logger.Println("Couldn't send the command to the monitor:", err)
+	}
+}
@@ -4,10 +4,24 @@ import (
 	"biocad-opcua/data"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	influxdb "github.com/influxdata/influxdb1-client/v2"
 )
 
+// outagesCapacity is the number of the outages kept until they're received.
+const outagesCapacity = 16
+
+// Outage is the period the measurements received during couldn't be written
+// to the time-series database, so they're missing in it.
+type Outage struct {
+	// Since is the time of the last successful write before the outage.
+	Since time.Time
+	// Until is the time of the last failed write.
+	Until time.Time
+}
+
 // DbClient represents a client for the time-series database.
 type DbClient struct {
 	address        string
@@ -17,6 +31,10 @@ type DbClient struct {
 	subscription   chan data.Measurement
 	pointsInSeries int
 	stop           chan interface{}
+	outages        chan Outage
+	lastWrite      time.Time
+	lastFailure    time.Time
+	failed         bool
 }
 
 // Connect establishes the connection with the time-series database.
@@ -79,6 +97,43 @@ func (dbclient *DbClient) Connect() error {
 	return nil
 }
 
+// LastParameterTimestamp returns the time of the last value of the parameter
+// of the server stored in the database. The time is zero if there are no values.
+func (dbclient *DbClient) LastParameterTimestamp(server, parameter string) (time.Time, error) {
+	query := influxdb.Query{
+		Command: fmt.Sprintf(`SELECT last("%s") FROM "parameters" WHERE "server" = '%s'`,
+			strings.Replace(strings.ToLower(parameter), `"`, `\"`, -1), strings.Replace(server, "'", `\'`, -1)),
+		Database: dbclient.database,
+	}
+	response, err := dbclient.influxClient.Query(query)
+
+	if err == nil {
+		err = response.Error()
+	}
+
+	dbclient.handleQueryError(err)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(response.Results) == 0 || len(response.Results[0].Series) == 0 ||
+		len(response.Results[0].Series[0].Values) == 0 {
+		return time.Time{}, nil
+	}
+
+	value, ok := response.Results[0].Series[0].Values[0][0].(string)
+
+	if !ok {
+		err = fmt.Errorf("Couldn't get the time from the result set")
+		dbclient.handleQueryError(err)
+
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, value)
+}
+
 // CloseConnection closes the database connection.
 func (dbclient *DbClient) CloseConnection() {
 	dbclient.influxClient.Close()
@@ -89,8 +144,17 @@ func (dbclient *DbClient) GetSubscriptionChannel() chan<- data.Measurement {
 	return dbclient.subscription
 }
 
+// Outages returns the channel receiving the outages of the database once the writes
+// succeed again. The series of points which couldn't be written are dropped, so the
+// outage is the gap the data has to be restored for from another source.
+func (dbclient *DbClient) Outages() <-chan Outage {
+	return dbclient.outages
+}
+
 // Start starts accepting measurements and writing them to the time-series database.
 func (dbclient *DbClient) Start() {
+	dbclient.lastWrite = time.Now()
+
 	go func() {
 		var (
 			counter int
@@ -114,8 +178,7 @@ func (dbclient *DbClient) Start() {
 			case measurement := <-dbclient.subscription:
 				// If series is full - write it to the database and create a new one.
 				if (counter+1)%dbclient.pointsInSeries == 0 {
-					err = dbclient.influxClient.Write(series)
-					dbclient.handleWriteToDbError(err)
+					dbclient.writeSeries(series)
 
 					series, err = influxdb.NewBatchPoints(influxdb.BatchPointsConfig{
 						Database:  dbclient.database,
@@ -146,6 +209,36 @@ func (dbclient *DbClient) Start() {
 	}()
 }
 
+// writeSeries writes the series of points to the database
+// and tracks the outages of the database.
+func (dbclient *DbClient) writeSeries(series influxdb.BatchPoints) {
+	err := dbclient.influxClient.Write(series)
+	dbclient.handleWriteToDbError(err)
+
+	if err != nil {
+		dbclient.failed = true
+		dbclient.lastFailure = time.Now()
+
+		return
+	}
+
+	if dbclient.failed {
+		outage := Outage{Since: dbclient.lastWrite, Until: dbclient.lastFailure}
+		dbclient.logger.Printf("The database is available again, the data since %v until %v is missing",
+			outage.Since, outage.Until)
+
+		// The outage isn't reported if nobody receives the outages.
+		select {
+		case dbclient.outages <- outage:
+		default:
+		}
+
+		dbclient.failed = false
+	}
+
+	dbclient.lastWrite = time.Now()
+}
+
 // Stop stops writing measurements to the database.
 func (dbclient *DbClient) Stop() {
 	dbclient.stop <- true
@@ -160,6 +253,7 @@ func NewDbClient(address, database string, logger *log.Logger, pointsInSeries in
 		pointsInSeries: pointsInSeries,
 		subscription:   make(chan data.Measurement),
 		stop:           make(chan interface{}),
+		outages:        make(chan Outage, outagesCapacity),
 	}
 }
 
@@ -187,6 +281,12 @@ func (dbclient *DbClient) handleCreatePointError(err error) {
 	}
 }
 
+func (dbclient *DbClient) handleQueryError(err error) {
+	if err != nil {
+		dbclient.logger.Println("Couldn't query the database:", err)
+	}
+}
+
 func (dbclient *DbClient) handleCheckDatabaseExistsError(err error) {
 	if err != nil {
 		dbclient.logger.Println("Couldn't check if the database exists:", err)
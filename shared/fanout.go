@@ -50,6 +50,14 @@ func (fanout *Fanout) SendMeasurement(measurement data.Measurement) {
 	}
 }
 
+// SendMeasurementSync sends a new measure to all the channels of the fanout one after another
+// and waits until each of them receives it, so the measurements sent this way keep their order.
+func (fanout *Fanout) SendMeasurementSync(measurement data.Measurement) {
+	for _, channel := range fanout.channels {
+		channel <- measurement
+	}
+}
+
 // NewFanout creates a new fanout to serve data to registered channels.
 func NewFanout() *Fanout {
 	return &Fanout{
@@ -0,0 +1,277 @@
+package shared
+
+import (
+	"biocad-opcua/data"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// MemorySource is the source of the parameters whose values are set by the application
+// instead of being acquired from the devices, e.g. the computed parameters
+// or the simulated devices in the tests.
+type MemorySource struct {
+	name string
+	// definitions are the parameters returned by LoadParameters.
+	definitions []Parameter
+	// parameters are the acquired parameters by their names.
+	parameters   map[string]Parameter
+	fanout       *Fanout
+	connected    bool
+	started      bool
+	synchronizer *sync.Mutex
+}
+
+// The memory source accepts the written values.
+var (
+	_ Source          = (*MemorySource)(nil)
+	_ ParameterWriter = (*MemorySource)(nil)
+)
+
+// NewMemorySource creates the source with the name loading the parameters.
+func NewMemorySource(name string, parameters []Parameter) *MemorySource {
+	return &MemorySource{
+		name:         name,
+		definitions:  append([]Parameter{}, parameters...),
+		parameters:   make(map[string]Parameter),
+		fanout:       NewFanout(),
+		synchronizer: new(sync.Mutex),
+	}
+}
+
+// Name identifies the source in the measurements.
+func (source *MemorySource) Name() string {
+	return source.name
+}
+
+// Connect makes the source ready to acquire the values.
+func (source *MemorySource) Connect() error {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	source.connected = true
+
+	return nil
+}
+
+// CloseConnection makes the source stop acquiring the values.
+func (source *MemorySource) CloseConnection() {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	source.connected = false
+}
+
+// Health returns nil if the source is connected and started.
+func (source *MemorySource) Health() error {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	if !source.connected {
+		return fmt.Errorf("The source '%s' is not connected", source.name)
+	}
+
+	if !source.started {
+		return fmt.Errorf("The source '%s' is stopped", source.name)
+	}
+
+	return nil
+}
+
+// LoadParameters returns the parameters the source has been created with
+// or the ones set with SetParameters.
+func (source *MemorySource) LoadParameters() ([]Parameter, error) {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	return append([]Parameter{}, source.definitions...), nil
+}
+
+// SetParameters replaces the parameters returned by LoadParameters.
+// The acquired parameters are brought in line with them by ReloadParameters.
+func (source *MemorySource) SetParameters(parameters []Parameter) {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	source.definitions = append([]Parameter{}, parameters...)
+}
+
+// ReloadParameters brings the acquired parameters in line with the loaded ones.
+// The parameters are identified by their names.
+func (source *MemorySource) ReloadParameters() (ParameterChanges, error) {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	changes := ParameterChanges{}
+	wanted := make(map[string]bool, len(source.definitions))
+
+	for _, parameter := range source.definitions {
+		wanted[parameter.Name] = true
+		current, ok := source.parameters[parameter.Name]
+
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, parameter)
+
+		case !reflect.DeepEqual(current, parameter):
+			changes.Updated = append(changes.Updated, parameter)
+
+		default:
+			continue
+		}
+
+		source.parameters[parameter.Name] = parameter
+	}
+
+	for name, parameter := range source.parameters {
+		if !wanted[name] {
+			delete(source.parameters, name)
+			changes.Removed = append(changes.Removed, parameter)
+		}
+	}
+
+	return changes, nil
+}
+
+// MonitorParameters makes the source acquire the parameters.
+// The parameters without the names are skipped.
+func (source *MemorySource) MonitorParameters(parameters []Parameter) []MonitorResult {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	results := make([]MonitorResult, len(parameters))
+
+	for i, parameter := range parameters {
+		results[i].Parameter = parameter
+
+		if parameter.Name == "" {
+			results[i].Err = fmt.Errorf("The parameter has no name")
+			continue
+		}
+
+		source.parameters[parameter.Name] = parameter
+	}
+
+	return results
+}
+
+// UnmonitorParameter stops acquiring the parameter with the given name.
+func (source *MemorySource) UnmonitorParameter(name string) error {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	if _, ok := source.parameters[name]; !ok {
+		return fmt.Errorf("The parameter '%s' is not monitored", name)
+	}
+
+	delete(source.parameters, name)
+
+	return nil
+}
+
+// HasParameter returns true if the source acquires the parameter with the name.
+func (source *MemorySource) HasParameter(name string) bool {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	_, ok := source.parameters[name]
+
+	return ok
+}
+
+// AddSubscriber adds the subscriber receiving the values of the parameters.
+func (source *MemorySource) AddSubscriber(channel chan<- data.Measurement) {
+	source.fanout.AddChannel(channel)
+}
+
+// RemoveSubscriber removes the subscriber, so it stops receiving the values.
+func (source *MemorySource) RemoveSubscriber(channel chan<- data.Measurement) error {
+	return source.fanout.RemoveChannel(channel)
+}
+
+// Start makes the source send the values set with SetValue to the subscribers.
+func (source *MemorySource) Start() {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	source.started = true
+}
+
+// Stop makes the source reject the values set with SetValue.
+func (source *MemorySource) Stop() {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	source.started = false
+}
+
+// SetValue sends the value of the parameter to the subscribers
+// as if it has been acquired from the devices.
+func (source *MemorySource) SetValue(name string, value data.Value) error {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	return source.setValue(name, value)
+}
+
+// setValue sends the value of the parameter to the subscribers.
+// The source must be locked by the caller.
+func (source *MemorySource) setValue(name string, value data.Value) error {
+	if _, ok := source.parameters[name]; !ok {
+		return fmt.Errorf("The parameter '%s' is not monitored", name)
+	}
+
+	if !source.connected || !source.started {
+		return fmt.Errorf("The source '%s' is stopped", source.name)
+	}
+
+	now := time.Now()
+
+	source.fanout.SendMeasurement(data.ParametersState{
+		Server:    source.name,
+		Timestamp: now,
+		Parameters: map[string]data.Sample{
+			name: {
+				Value:           value,
+				Quality:         data.NewQuality(0, data.QualityGood),
+				SourceTimestamp: now,
+				ServerTimestamp: now,
+			},
+		},
+	})
+
+	return nil
+}
+
+// WriteParameter sets the value of the writable parameter
+// if the value is within the limits of the parameter.
+func (source *MemorySource) WriteParameter(command data.WriteCommand) data.CommandResult {
+	source.synchronizer.Lock()
+	defer source.synchronizer.Unlock()
+
+	parameter, ok := source.parameters[command.Parameter]
+
+	if !ok {
+		return data.CommandResult{Error: fmt.Sprintf("The parameter '%s' is not monitored", command.Parameter)}
+	}
+
+	if !parameter.Writable {
+		return data.CommandResult{Error: fmt.Sprintf("The parameter '%s' is not writable", parameter.Name)}
+	}
+
+	if number, ok := command.Value.Float(); ok && parameter.Limits != nil {
+		if number < parameter.Limits.LowerBound || number > parameter.Limits.UpperBound {
+			return data.CommandResult{Error: fmt.Sprintf("The value %v is out of the limits [%v, %v]",
+				number, parameter.Limits.LowerBound, parameter.Limits.UpperBound)}
+		}
+	}
+
+	err := source.setValue(parameter.Name, command.Value)
+
+	if err != nil {
+		return data.CommandResult{Error: err.Error()}
+	}
+
+	return data.CommandResult{Status: data.QualityGood}
+}
@@ -0,0 +1,170 @@
+package shared
+
+import (
+	"biocad-opcua/data"
+	"testing"
+	"time"
+)
+
+// receiveState waits for the state of the parameters sent by the source.
+func receiveState(t *testing.T, channel <-chan data.Measurement) data.ParametersState {
+	select {
+	case measurement := <-channel:
+		state, ok := measurement.(data.ParametersState)
+
+		if !ok {
+			t.Fatalf("Received %T, want the state of the parameters", measurement)
+		}
+
+		return state
+
+	case <-time.After(time.Second):
+		t.Fatal("The source hasn't sent the value")
+	}
+
+	return data.ParametersState{}
+}
+
+// startMemorySource creates the started source acquiring the parameters
+// and the channel receiving their values.
+func startMemorySource(t *testing.T, parameters []Parameter) (*MemorySource, chan data.Measurement) {
+	source := NewMemorySource("memory", parameters)
+	channel := make(chan data.Measurement, 1)
+	source.AddSubscriber(channel)
+
+	err := source.Connect()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := source.LoadParameters()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range source.MonitorParameters(loaded) {
+		if result.Err != nil {
+			t.Fatalf("Couldn't monitor the parameter '%s': %s", result.Parameter.Name, result.Err)
+		}
+	}
+
+	source.Start()
+
+	return source, channel
+}
+
+func TestMemorySourceSetValue(t *testing.T) {
+	source, channel := startMemorySource(t, []Parameter{{Name: "Temperature"}})
+
+	if err := source.Health(); err != nil {
+		t.Errorf("Health() = %s, want nil", err)
+	}
+
+	err := source.SetValue("Temperature", data.NewNumber(36.6))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := receiveState(t, channel)
+
+	if state.Server != "memory" || state.Parameters["Temperature"].Number != 36.6 {
+		t.Errorf("Received %+v, want Temperature 36.6 of the server 'memory'", state)
+	}
+
+	if state.Parameters["Temperature"].Quality.IsBad() {
+		t.Errorf("The value has the bad quality %+v", state.Parameters["Temperature"].Quality)
+	}
+
+	if err := source.SetValue("Pressure", data.NewNumber(1)); err == nil {
+		t.Error("SetValue() of the parameter which isn't monitored succeeded")
+	}
+
+	source.Stop()
+
+	if err := source.Health(); err == nil {
+		t.Error("Health() of the stopped source = nil, want an error")
+	}
+
+	if err := source.SetValue("Temperature", data.NewNumber(1)); err == nil {
+		t.Error("SetValue() of the stopped source succeeded")
+	}
+}
+
+func TestMemorySourceWriteParameter(t *testing.T) {
+	source, channel := startMemorySource(t, []Parameter{
+		{Name: "Setpoint", Writable: true, Limits: &data.Bounds{LowerBound: 0, UpperBound: 100}},
+		{Name: "Temperature"},
+	})
+
+	tests := []struct {
+		command data.WriteCommand
+		good    bool
+	}{
+		{command: data.WriteCommand{Parameter: "Setpoint", Value: data.NewNumber(50)}, good: true},
+		{command: data.WriteCommand{Parameter: "Setpoint", Value: data.NewNumber(150)}},
+		{command: data.WriteCommand{Parameter: "Temperature", Value: data.NewNumber(20)}},
+		{command: data.WriteCommand{Parameter: "Pressure", Value: data.NewNumber(1)}},
+	}
+
+	for _, test := range tests {
+		result := source.WriteParameter(test.command)
+
+		if result.IsGood() != test.good {
+			t.Errorf("WriteParameter(%s = %v) = %+v, want good %t",
+				test.command.Parameter, test.command.Value.Number, result, test.good)
+		}
+
+		if !test.good {
+			continue
+		}
+
+		state := receiveState(t, channel)
+
+		if state.Parameters[test.command.Parameter].Number != test.command.Value.Number {
+			t.Errorf("Received %+v after writing %v", state, test.command.Value.Number)
+		}
+	}
+}
+
+func TestMemorySourceReloadParameters(t *testing.T) {
+	source, _ := startMemorySource(t, []Parameter{
+		{Name: "Temperature", Address: "t1"},
+		{Name: "Pressure", Address: "p1"},
+	})
+
+	source.SetParameters([]Parameter{
+		{Name: "Temperature", Address: "t2"},
+		{Name: "Humidity", Address: "h1"},
+	})
+
+	changes, err := source.ReloadParameters()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes.Added) != 1 || changes.Added[0].Name != "Humidity" {
+		t.Errorf("Added %+v, want Humidity", changes.Added)
+	}
+
+	if len(changes.Updated) != 1 || changes.Updated[0].Address != "t2" {
+		t.Errorf("Updated %+v, want Temperature at t2", changes.Updated)
+	}
+
+	if len(changes.Removed) != 1 || changes.Removed[0].Name != "Pressure" {
+		t.Errorf("Removed %+v, want Pressure", changes.Removed)
+	}
+
+	if source.HasParameter("Pressure") || !source.HasParameter("Humidity") {
+		t.Error("The acquired parameters don't match the reloaded ones")
+	}
+
+	changes, err = source.ReloadParameters()
+
+	if err != nil || !changes.IsEmpty() {
+		t.Errorf("ReloadParameters() = %+v, %v again, want no changes", changes, err)
+	}
+}
@@ -8,6 +8,15 @@ import (
 	nats "github.com/nats-io/nats.go"
 )
 
+// Subtopics of the topic the measurements other than parameter states are published on.
+const (
+	connectionSubtopic  = "connection"
+	alertsSubtopic      = "alerts"
+	auditSubtopic       = "audit"
+	stalenessSubtopic   = "staleness"
+	diagnosticsSubtopic = "diagnostics"
+)
+
 // Publisher sends all incoming messages to other services through message broker service.
 type Publisher struct {
 	address string
@@ -43,21 +52,21 @@ func (publisher *Publisher) Start() {
 		for {
 			select {
 			case measure := <-publisher.source:
-				params, ok := measure.(data.ParametersState)
+				subject, ok := measurementSubject(publisher.topic, measure)
 
 				if !ok {
 					publisher.logger.Println("Type assertion failed for", measure)
 					continue
 				}
 
-				data, err := json.MarshalIndent(params, "", "    ")
+				data, err := json.MarshalIndent(measure, "", "    ")
 				publisher.handleJSONMarshalError(err)
 
 				if err != nil {
 					continue
 				}
 
-				err = publisher.conn.Publish(publisher.topic, data)
+				err = publisher.conn.Publish(subject, data)
 				publisher.handlePublishError(err)
 
 			case <-publisher.stop:
@@ -77,6 +86,34 @@ func (publisher *Publisher) CloseConnection() {
 	publisher.conn.Close()
 }
 
+// measurementSubject returns the subject of the topic the measurement is published on.
+// Parameter states are published on the topic itself, other measurements
+// are published on its subtopics.
+func measurementSubject(topic string, measure data.Measurement) (string, bool) {
+	switch measure.(type) {
+	case data.ParametersState:
+		return topic, true
+
+	case data.ConnectionEvent:
+		return topic + "." + connectionSubtopic, true
+
+	case data.Alert:
+		return topic + "." + alertsSubtopic, true
+
+	case data.WriteAudit:
+		return topic + "." + auditSubtopic, true
+
+	case data.StalenessEvent:
+		return topic + "." + stalenessSubtopic, true
+
+	case data.ServerDiagnostics:
+		return topic + "." + diagnosticsSubtopic, true
+
+	default:
+		return "", false
+	}
+}
+
 // NewPublisher creates a new publisher to listen for new messages to send them to other services of the application.
 func NewPublisher(address, topic string, logger *log.Logger) *Publisher {
 	return &Publisher{
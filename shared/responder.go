@@ -0,0 +1,141 @@
+package shared
+
+import (
+	"biocad-opcua/data"
+	"encoding/json"
+	"log"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+// Responder receives commands from the message broker and replies with their results.
+type Responder struct {
+	address       string
+	conn          *nats.Conn
+	logger        *log.Logger
+	topic         string
+	subscriptions []*nats.Subscription
+}
+
+// Connect establishes the connection with the message broker.
+func (responder *Responder) Connect() error {
+	conn, err := nats.Connect(responder.address)
+	responder.handleConnectionError(err)
+
+	if err != nil {
+		return err
+	}
+
+	responder.conn = conn
+
+	return err
+}
+
+// HandleWrite makes the responder execute the write commands with the handler.
+func (responder *Responder) HandleWrite(handler func(data.WriteCommand) data.CommandResult) error {
+	return responder.serve(writeSubject, func(payload []byte) (interface{}, error) {
+		var command data.WriteCommand
+		err := json.Unmarshal(payload, &command)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(command), nil
+	})
+}
+
+// HandleCall makes the responder execute the method calls with the handler.
+func (responder *Responder) HandleCall(handler func(data.CallCommand) data.CommandResult) error {
+	return responder.serve(callSubject, func(payload []byte) (interface{}, error) {
+		var command data.CallCommand
+		err := json.Unmarshal(payload, &command)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(command), nil
+	})
+}
+
+// serve subscribes to the subject of the topic and replies to each command
+// with the result returned by the handler.
+func (responder *Responder) serve(subject string, handler func([]byte) (interface{}, error)) error {
+	sub, err := responder.conn.Subscribe(responder.topic+"."+subject, func(message *nats.Msg) {
+		result, err := handler(message.Data)
+		responder.handleJSONUnmarshalError(err)
+
+		if err != nil {
+			result = data.CommandResult{Error: err.Error()}
+		}
+
+		bytes, err := json.Marshal(result)
+		responder.handleJSONMarshalError(err)
+
+		if err != nil {
+			return
+		}
+
+		err = message.Respond(bytes)
+		responder.handleReplyError(err)
+	})
+	responder.handleSubscriptionError(err)
+
+	if err != nil {
+		return err
+	}
+
+	responder.subscriptions = append(responder.subscriptions, sub)
+
+	return nil
+}
+
+// CloseConnection stops receiving commands and closes the connection with the message broker.
+func (responder *Responder) CloseConnection() {
+	for _, sub := range responder.subscriptions {
+		sub.Unsubscribe()
+	}
+
+	responder.conn.Close()
+}
+
+// NewResponder creates a new responder to receive commands on the topic.
+func NewResponder(address, topic string, logger *log.Logger) *Responder {
+	return &Responder{
+		address:       address,
+		topic:         topic,
+		logger:        logger,
+		subscriptions: make([]*nats.Subscription, 0),
+	}
+}
+
+func (responder *Responder) handleConnectionError(err error) {
+	if err != nil {
+		responder.logger.Println("Couldn't connect to the NATS message broking service:", err)
+	}
+}
+
+func (responder *Responder) handleSubscriptionError(err error) {
+	if err != nil {
+		responder.logger.Println("Couldn't subscribe to the commands:", err)
+	}
+}
+
+func (responder *Responder) handleJSONMarshalError(err error) {
+	if err != nil {
+		responder.logger.Println("Couldn't serialize the reply to JSON:", err)
+	}
+}
+
+func (responder *Responder) handleJSONUnmarshalError(err error) {
+	if err != nil {
+		responder.logger.Println("Couldn't deserialize the command:", err)
+	}
+}
+
+func (responder *Responder) handleReplyError(err error) {
+	if err != nil {
+		responder.logger.Println("Couldn't send the reply to the commander:", err)
+	}
+}
@@ -0,0 +1,146 @@
+package shared
+
+import (
+	"biocad-opcua/data"
+	"io"
+	"time"
+)
+
+// Parameter is the value the source acquires from the devices regardless of the protocol.
+type Parameter struct {
+	Name string
+	// Address identifies the value on the devices in terms of the protocol
+	// of the source, e.g. the NodeID of the OPC UA variable.
+	Address string
+	// Writable allows writing the parameter value through the source.
+	Writable bool
+	// Limits are the bounds the written numeric values must be within.
+	// Any value is accepted if the limits are nil.
+	Limits *data.Bounds
+	// Bounds are the initial alerting bounds of the parameter.
+	Bounds   *data.Bounds
+	Metadata data.ParameterMetadata
+	// MaxAge is the time after the last update the parameter becomes stale in.
+	// The parameter never becomes stale if it's zero.
+	MaxAge time.Duration
+}
+
+// MonitorResult is the outcome of the request to acquire the parameter.
+type MonitorResult struct {
+	Parameter Parameter
+	// Err is the reason the parameter isn't acquired or nil if it is
+	// (or will be as soon as the connection is restored).
+	Err error
+	// Retrying is true if the devices have rejected the parameter,
+	// but the source keeps trying to acquire it.
+	Retrying bool
+}
+
+// ParameterChanges are the differences between the acquired parameters
+// and the parameters obtained from the parameter source.
+type ParameterChanges struct {
+	// Added are the parameters which have started being acquired.
+	Added []Parameter
+	// Updated are the parameters whose definitions have changed.
+	Updated []Parameter
+	// Removed are the parameters which have stopped being acquired.
+	Removed []Parameter
+}
+
+// IsEmpty returns true if the acquired parameters haven't changed.
+func (changes ParameterChanges) IsEmpty() bool {
+	return len(changes.Added) == 0 && len(changes.Updated) == 0 && len(changes.Removed) == 0
+}
+
+// Source acquires the values of the parameters from the devices over some protocol
+// and sends them to the subscribers as measurements. The monitor of the OPC UA server
+// is the source, other protocols are added by implementing the interface.
+// The features the protocols don't have in common are the separate interfaces below.
+type Source interface {
+	// Name identifies the source in the measurements.
+	Name() string
+	// Connect establishes the connection with the devices.
+	Connect() error
+	CloseConnection()
+	// Health returns nil if the source receives the values of the parameters
+	// or the reason it doesn't.
+	Health() error
+	// LoadParameters returns the parameters to acquire from the parameter source.
+	LoadParameters() ([]Parameter, error)
+	// ReloadParameters brings the acquired parameters in line with the parameter source.
+	ReloadParameters() (ParameterChanges, error)
+	MonitorParameters(parameters []Parameter) []MonitorResult
+	UnmonitorParameter(name string) error
+	HasParameter(name string) bool
+	AddSubscriber(channel chan<- data.Measurement)
+	RemoveSubscriber(channel chan<- data.Measurement) error
+	// Start starts acquiring the values and restoring the connection when it's lost.
+	Start()
+	Stop()
+}
+
+// ParameterWriter is the source the values of the parameters can be written to.
+type ParameterWriter interface {
+	WriteParameter(command data.WriteCommand) data.CommandResult
+}
+
+// MethodCaller is the source the methods of the devices can be called on.
+type MethodCaller interface {
+	HasMethod(name string) bool
+	CallMethod(command data.CallCommand) data.CommandResult
+}
+
+// ReloadNotifier is the source which asks for reloading its parameters,
+// e.g. when they can only be discovered after connecting to the devices.
+type ReloadNotifier interface {
+	NotifyReload(channel chan<- interface{})
+}
+
+// EventSource is the source which sends the events and the alarms of the devices.
+type EventSource interface {
+	MonitorEvents() error
+}
+
+// HistoryStore provides the time of the last value of the parameter
+// stored in the time-series database.
+type HistoryStore interface {
+	LastParameterTimestamp(server, parameter string) (time.Time, error)
+}
+
+// Backfiller is the source which can restore the values of the parameters
+// missing in the time-series database from the history of the devices.
+type Backfiller interface {
+	// EnableBackfill makes the source fill the gaps in the stored data
+	// each time the connection with the devices is restored.
+	EnableBackfill(store HistoryStore, channel chan<- data.Measurement)
+	// BackfillPeriod fills the gap in the stored data for the period.
+	BackfillPeriod(since, until time.Time)
+}
+
+// MetadataStore keeps the descriptions and the alerting bounds of the parameters.
+type MetadataStore interface {
+	SetParameterMetadata(server, parameter string, metadata data.ParameterMetadata) error
+	SetInitialParameterBounds(server, parameter string, bounds data.Bounds) (bool, error)
+}
+
+// Describer is the source which reads the metadata of the parameters from the devices.
+type Describer interface {
+	EnableMetadata(store MetadataStore)
+}
+
+// DiagnosticsStore keeps the latest diagnostics of the servers.
+type DiagnosticsStore interface {
+	SetServerDiagnostics(diagnostics data.ServerDiagnostics) error
+}
+
+// Diagnosable is the source which sends the diagnostics of the link to the devices.
+type Diagnosable interface {
+	EnableDiagnostics(store DiagnosticsStore)
+}
+
+// Recordable is the source which records the data received from the devices to replay it later.
+type Recordable interface {
+	// StartRecording starts writing the data to the file.
+	// Closing the result stops the recording.
+	StartRecording(filePath string) (io.Closer, error)
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"biocad-opcua/data"
 	"encoding/json"
+	"fmt"
 	"log"
 
 	"github.com/nats-io/nats.go"
@@ -35,22 +36,28 @@ func (subscriber *Subscriber) Connect() error {
 // Start starts listening for new messages from the topic.
 func (subscriber *Subscriber) Start() {
 	go func() {
-		// Subscribe to the topic.
+		// Subscribe to the topic and its subtopics.
 		source := make(chan *nats.Msg, 64)
-		sub, err := subscriber.conn.ChanSubscribe(subscriber.topic, source)
-		subscriber.handleSubscriptionError(err)
+		subjects := []string{
+			subscriber.topic,
+			subscriber.topic + ".>",
+		}
+
+		for _, subject := range subjects {
+			sub, err := subscriber.conn.ChanSubscribe(subject, source)
+			subscriber.handleSubscriptionError(err)
 
-		if err != nil {
-			return
+			if err != nil {
+				return
+			}
+			defer sub.Unsubscribe()
 		}
-		defer sub.Unsubscribe()
 
 		// Get messages, deserialize them and send to listeners.
 		for {
 			select {
 			case message := <-source:
-				var measure data.ParametersState
-				err = json.Unmarshal(message.Data, &measure)
+				measure, err := subscriber.decodeMeasurement(message)
 				subscriber.handleJSONUnmarshalError(err)
 
 				if err != nil {
@@ -66,6 +73,51 @@ func (subscriber *Subscriber) Start() {
 	}()
 }
 
+// decodeMeasurement deserializes the measurement of the type
+// corresponding to the subject the message was received on.
+func (subscriber *Subscriber) decodeMeasurement(message *nats.Msg) (data.Measurement, error) {
+	switch message.Subject {
+	case subscriber.topic:
+		var measure data.ParametersState
+		err := json.Unmarshal(message.Data, &measure)
+
+		return measure, err
+
+	case subscriber.topic + "." + connectionSubtopic:
+		var event data.ConnectionEvent
+		err := json.Unmarshal(message.Data, &event)
+
+		return event, err
+
+	case subscriber.topic + "." + alertsSubtopic:
+		var alert data.Alert
+		err := json.Unmarshal(message.Data, &alert)
+
+		return alert, err
+
+	case subscriber.topic + "." + auditSubtopic:
+		var audit data.WriteAudit
+		err := json.Unmarshal(message.Data, &audit)
+
+		return audit, err
+
+	case subscriber.topic + "." + stalenessSubtopic:
+		var event data.StalenessEvent
+		err := json.Unmarshal(message.Data, &event)
+
+		return event, err
+
+	case subscriber.topic + "." + diagnosticsSubtopic:
+		var diagnostics data.ServerDiagnostics
+		err := json.Unmarshal(message.Data, &diagnostics)
+
+		return diagnostics, err
+
+	default:
+		return nil, fmt.Errorf("unknown subject %s", message.Subject)
+	}
+}
+
 // AddChannelSubscriber adds the given channel to the message broker client's fanout.
 func (subscriber *Subscriber) AddChannelSubscriber(channel chan<- data.Measurement) {
 	subscriber.fanout.AddChannel(channel)
@@ -2,12 +2,20 @@ var socket = new WebSocket("ws://" + window.location.host + "/api/measures");
 var output = document.getElementById("data-field");
 let params = [];
 let values = [];
+let stale = {};
 var LowerBound=0, UpperBound=0;
 var selectParameter = 0;
 var dataLength = 50;
 var writeMessage = function(message) {
     //output.innerHTML += "<p>service: " + message + "</p>";
 }
+// Parameters of different servers may have the same names.
+var parameterKey = function(server, parameter) {
+    return server + '/' + parameter;
+}
+var parameterURL = function(param) {
+    return "http://" + window.location.host + "/api/" + encodeURIComponent(param.Server) + "/" + encodeURIComponent(param.Parameter);
+}
 
 socket.onopen = function () {
     loadParametrs();
@@ -25,15 +33,50 @@ socket.onclose = function (event) {
 
 socket.onmessage = function(event) {
     var myJson = JSON.parse(event.data);
+    // The diagnostics carry the state of the server too, so they're checked before the connection events.
+    if(myJson.NotificationsPerSecond !== undefined)
+    {
+        writeMessage('OPC UA server ' + myJson.Server + ' ' + myJson.State + ': ' + myJson.NotificationsPerSecond.toFixed(1) + ' notifications/s'
+            + (myJson.MissedMessages > 0 ? ', ' + myJson.MissedMessages + ' messages missed' : ''));
+        return;
+    }
+    if(myJson.State !== undefined)
+    {
+        if(myJson.State == 'failover')
+        {
+            writeMessage('OPC UA server ' + myJson.Server + ' failed over from ' + myJson.PreviousEndpoint + ' to ' + myJson.Endpoint);
+            return;
+        }
+        writeMessage('OPC UA connection ' + myJson.State + ': ' + myJson.Endpoint);
+        return;
+    }
+    if(myJson.Type !== undefined)
+    {
+        writeMessage('Alert ' + myJson.Type + ' (' + myJson.Parameter + '): ' + myJson.Message);
+        return;
+    }
+    if(myJson.Stale !== undefined)
+    {
+        stale[parameterKey(myJson.Server, myJson.Parameter)] = myJson.Stale;
+        writeMessage(myJson.Parameter + (myJson.Stale ? ' is stale since ' + myJson.LastUpdate : ' is updated again'));
+        Chart();
+        return;
+    }
+    if(myJson.User !== undefined)
+    {
+        writeMessage(myJson.User + ' wrote ' + myJson.Parameter + ': ' + myJson.Status + (myJson.Error ? ' (' + myJson.Error + ')' : ''));
+        return;
+    }
     var Time = new Date(myJson.Timestamp);
     Time.setMilliseconds(0);
     for(t in params)
     {
         for (var i in myJson.Parameters) 
         {
-            if(i==params[t])
+            var value = myJson.Parameters[i];
+            if(myJson.Server==params[t].Server && i==params[t].Parameter && (value.Kind == 'number' || value.Kind == 'boolean'))
             {
-                values[t][1].push({x: Time,y:myJson.Parameters[i]});
+                values[t][1].push({x: Time,y:value.Kind == 'number' ? value.Number : Number(value.Boolean)});
                 if(values[t][1].length>dataLength)
                 {
                     values[t][1].shift();
@@ -57,11 +100,15 @@ function loadParametrs() {
     {
         var j=0;
         myParams = JSON.parse(x.responseText);
-        for (i in myParams) 
+        for (var server in myParams)
         {
-            document.getElementById("parameters").innerHTML += "<option value="+ j++ +">" + myParams[i] + "</option>";
-            params.push(myParams[i]);
-            values.push([myParams[i],[]]);
+            for (i in myParams[server])
+            {
+                var key = parameterKey(server, myParams[server][i]);
+                document.getElementById("parameters").innerHTML += "<option value="+ j++ +">" + key + "</option>";
+                params.push({Server: server, Parameter: myParams[server][i]});
+                values.push([key,[]]);
+            }
         }
         loadBound(params[0]);
     }
@@ -75,7 +122,7 @@ function loadParametrs() {
 function loadBound(myParams) {
     var myBound;
     var y = new XMLHttpRequest();
-    y.open("GET", "http://" + window.location.host + "/api/" + myParams + "/bounds", true);
+    y.open("GET", parameterURL(myParams) + "/bounds", true);
     y.onload = function ()
     {
         myBound=  JSON.parse(y.responseText);
@@ -90,7 +137,7 @@ function loadBound(myParams) {
     {
         UpperBound = 0;
         LowerBound = 0;
-        alert("Не удалось загрузить значения аварийных установок для: " + myParams);
+        alert("Не удалось загрузить значения аварийных установок для: " + parameterKey(myParams.Server, myParams.Parameter));
     }
 }
 
@@ -119,12 +166,12 @@ function editBound() {
 
 function setBound(myParams, a, b) {
     var z = new XMLHttpRequest();
-    z.open("PATCH", "http://" + window.location.host + "/api/" + myParams + "/bounds", true);
+    z.open("PATCH", parameterURL(myParams) + "/bounds", true);
     z.setRequestHeader("Content-Type", "application/x-www-form-urlencoded");
     z.send('{"LowerBound": ' + b + ',"UpperBound": ' + a + '}');
     z.onerror = function()
     {
-        alert("Не удалось изменить значения аварийных установок для: " + myParams);
+        alert("Не удалось изменить значения аварийных установок для: " + parameterKey(myParams.Server, myParams.Parameter));
     }
     z.onreadystatechange = function() { 
         if (this.status === 200) {
@@ -134,6 +181,8 @@ function setBound(myParams, a, b) {
 }
 
 function Chart() {
+    // Stale parameters are greyed out.
+    var lineColor = stale[values[selectParameter][0]] ? "#808080" : "#21CBD1";
     var chart = new CanvasJS.Chart("chartContainer", {
     exportEnabled: true,
     theme: "dark1",
@@ -162,7 +211,7 @@ function Chart() {
 	data: [{
         name: "Type 1 Filter",
         type: "line",
-        color: "#21CBD1",//цвет для линий должен быть одинаковым
+        color: lineColor,//цвет для линий должен быть одинаковым
 		markerSize: 0,
 		dataPoints: values[selectParameter][1] 
     },
@@ -170,7 +219,7 @@ function Chart() {
         name: "",
         type: "line",
         markerSize: 0,
-        color: "#21CBD1",//цвет для линий должен быть одинаковым
+        color: lineColor,//цвет для линий должен быть одинаковым
         showInLegend: false,
         axisYType: "secondary",
 		dataPoints: values[selectParameter][1] 
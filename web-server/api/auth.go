@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// userHeader is the HTTP header containing the name of the user
+// set by the authenticating proxy.
+const userHeader = "X-User"
+
+// userKey is the key of the authenticated user in the request context.
+type userKey struct{}
+
+// Authenticator identifies the users sending the commands to the servers.
+// The user is identified either by the bearer token or by the name set
+// in the X-User header by one of the trusted authenticating proxies.
+type Authenticator struct {
+	// tokens are the names of the users by their tokens.
+	tokens map[string]string
+	// proxies are the IP addresses of the trusted proxies.
+	proxies map[string]bool
+}
+
+// NewAuthenticator creates an authenticator accepting the tokens of the users
+// and the X-User header of the requests from the proxies with the addresses.
+// The requests can't be authenticated if neither of them is given.
+func NewAuthenticator(tokens map[string]string, proxies []string) *Authenticator {
+	auth := &Authenticator{
+		tokens:  make(map[string]string, len(tokens)),
+		proxies: make(map[string]bool, len(proxies)),
+	}
+
+	for token, user := range tokens {
+		auth.tokens[token] = user
+	}
+
+	for _, proxy := range proxies {
+		auth.proxies[strings.TrimSpace(proxy)] = true
+	}
+
+	return auth
+}
+
+// LoadTokens reads the tokens of the users from the file. Each line contains
+// the name of the user followed by the token separated with spaces, e.g.:
+//
+//	operator 4f2a9c0e7d1b
+//
+// Empty lines and lines starting with '#' are skipped.
+func LoadTokens(filePath string) (map[string]string, error) {
+	file, err := os.Open(filePath)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	tokens := make(map[string]string)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected the user name and the token", filePath, line)
+		}
+
+		if _, ok := tokens[fields[1]]; ok {
+			return nil, fmt.Errorf("%s:%d: the token of the user '%s' is not unique", filePath, line, fields[0])
+		}
+
+		tokens[fields[1]] = fields[0]
+	}
+
+	return tokens, scanner.Err()
+}
+
+// IsEnabled returns true if the authenticator can authenticate any request.
+func (auth *Authenticator) IsEnabled() bool {
+	return len(auth.tokens) > 0 || len(auth.proxies) > 0
+}
+
+// authenticate returns the name of the user sending the request.
+// The second result is false if the request can't be authenticated.
+func (auth *Authenticator) authenticate(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return auth.userByToken(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	// Anyone can set the header, so it's only trusted if it's set by the proxy.
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil || !auth.proxies[host] {
+		return "", false
+	}
+
+	user := r.Header.Get(userHeader)
+
+	return user, user != ""
+}
+
+// userByToken returns the name of the user with the token comparing
+// the token with all the known ones in constant time.
+func (auth *Authenticator) userByToken(token string) (string, bool) {
+	found := ""
+
+	for known, user := range auth.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			found = user
+		}
+	}
+
+	return found, found != ""
+}
+
+// authMiddleware rejects the requests which can't be authenticated
+// and puts the name of the user to the context of the other ones.
+func authMiddleware(auth *Authenticator, ctl *controller) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := auth.authenticate(r)
+
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				ctl.handleWebError(w, http.StatusUnauthorized, "The user is not authenticated")
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+		})
+	}
+}
+
+// authenticatedUser returns the name of the user authenticated by the middleware.
+func authenticatedUser(r *http.Request) string {
+	user, _ := r.Context().Value(userKey{}).(string)
+	return user
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	auth := NewAuthenticator(map[string]string{"secret": "operator"}, []string{"10.0.0.1"})
+
+	tests := []struct {
+		remoteAddr string
+		headers    map[string]string
+		user       string
+		ok         bool
+	}{
+		{"192.168.0.5:4000", map[string]string{"Authorization": "Bearer secret"}, "operator", true},
+		{"192.168.0.5:4000", map[string]string{"Authorization": "Bearer wrong"}, "", false},
+		{"192.168.0.5:4000", map[string]string{"Authorization": "Basic c2VjcmV0"}, "", false},
+		{"10.0.0.1:4000", map[string]string{userHeader: "engineer"}, "engineer", true},
+		{"10.0.0.1:4000", map[string]string{}, "", false},
+		// The header set by the client itself isn't trusted.
+		{"192.168.0.5:4000", map[string]string{userHeader: "engineer"}, "", false},
+		{"192.168.0.5:4000", map[string]string{}, "", false},
+		// The token takes precedence over the header of the proxy.
+		{"10.0.0.1:4000", map[string]string{"Authorization": "Bearer wrong", userHeader: "engineer"}, "", false},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest("PUT", "/simulation/Temperature/value", nil)
+		r.RemoteAddr = test.remoteAddr
+
+		for name, value := range test.headers {
+			r.Header.Set(name, value)
+		}
+
+		user, ok := auth.authenticate(r)
+
+		if user != test.user || ok != test.ok {
+			t.Errorf("#%d: authenticate() = %q, %t, want %q, %t", i, user, ok, test.user, test.ok)
+		}
+	}
+}
+
+func TestAuthenticatorDisabled(t *testing.T) {
+	auth := NewAuthenticator(nil, nil)
+
+	if auth.IsEnabled() {
+		t.Error("the authenticator without the tokens and the proxies is enabled")
+	}
+
+	r := httptest.NewRequest("PUT", "/simulation/Temperature/value", nil)
+	r.Header.Set(userHeader, "engineer")
+
+	if _, ok := auth.authenticate(r); ok {
+		t.Error("the authenticator without the tokens and the proxies has authenticated the request")
+	}
+}
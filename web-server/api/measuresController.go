@@ -9,6 +9,8 @@ import (
 	"log"
 	"net/http"
 
+	nats "github.com/nats-io/nats.go"
+
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 )
@@ -29,12 +31,28 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// valueRequest is the body of the request writing the parameter value.
+type valueRequest struct {
+	// Value is a number, a boolean or a string.
+	Value interface{}
+}
+
+// callRequest is the body of the request calling the method.
+type callRequest struct {
+	// Server the method belongs to. Optional if the method name is unique.
+	Server string
+	// Arguments are numbers, booleans or strings.
+	Arguments []interface{}
+}
+
 // MeasuresController is responsible for handling HTTP requests
 // related to monitored OPC UA parameters.
 type MeasuresController struct {
 	controller
-	sub   *shared.Subscriber
-	cache *shared.Cache
+	sub       *shared.Subscriber
+	cache     *shared.Cache
+	commander *shared.Commander
+	auth      *Authenticator
 }
 
 // measures is a Websocket handler to send monitoring data to the web client.
@@ -55,16 +73,7 @@ func (ctl *MeasuresController) measures(w http.ResponseWriter, r *http.Request)
 	defer ctl.sub.RemoveChannelSubscriber(source)
 
 	for measure := range source {
-		params, ok := measure.(data.ParametersState)
-
-		if !ok {
-			ctl.handleInternalError("Type assertion failed", fmt.Errorf("invalid data type"))
-			ctl.handleWebsocketSendMessageError(fmt.Errorf("Type assertion failed"))
-
-			return
-		}
-
-		err = conn.WriteJSON(params)
+		err = conn.WriteJSON(measure)
 		ctl.handleWebsocketSendMessageError(err)
 
 		if err != nil {
@@ -99,6 +108,7 @@ func (ctl *MeasuresController) getAllParameters(w http.ResponseWriter, r *http.R
 // getBoundsForParameter sends the parameter alerting bounds to the client.
 func (ctl *MeasuresController) getBoundsForParameter(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	server := vars["server"]
 	parameter, ok := vars["parameter"]
 
 	if !ok {
@@ -108,7 +118,7 @@ func (ctl *MeasuresController) getBoundsForParameter(w http.ResponseWriter, r *h
 		return
 	}
 
-	bounds, err := ctl.cache.GetParameterBounds(parameter)
+	bounds, err := ctl.cache.GetParameterBounds(server, parameter)
 
 	if err != nil {
 		ctl.handleInternalError("Couldn't get bounds for the parameter", err)
@@ -118,14 +128,41 @@ func (ctl *MeasuresController) getBoundsForParameter(w http.ResponseWriter, r *h
 		return
 	}
 
+	data, err := json.MarshalIndent(bounds, "", "    ")
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't marshal the object to JSON", err)
+		ctl.handleWebError(w, http.StatusInternalServerError, "Couldn't build a JSON response")
+
+		return
+	}
+
+	ctl.sendData(w, data)
+}
+
+// getMetadataForParameter returns the unit, the description and the data type of the parameter.
+func (ctl *MeasuresController) getMetadataForParameter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	server := vars["server"]
+	parameter, ok := vars["parameter"]
+
 	if !ok {
-		ctl.handleWebError(w, http.StatusNotFound,
-			fmt.Sprintf("Parameter '%s' is not monitored on the server", parameter))
+		ctl.handleWebError(w, http.StatusNotFound, "Parameter is missing")
 
 		return
 	}
 
-	data, err := json.MarshalIndent(bounds, "", "    ")
+	metadata, err := ctl.cache.GetParameterMetadata(server, parameter)
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't get metadata for the parameter", err)
+		ctl.handleWebError(w, http.StatusInternalServerError,
+			"Couldn't obtain parameter metadata from the cache")
+
+		return
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "    ")
 
 	if err != nil {
 		ctl.handleInternalError("Couldn't marshal the object to JSON", err)
@@ -137,9 +174,33 @@ func (ctl *MeasuresController) getBoundsForParameter(w http.ResponseWriter, r *h
 	ctl.sendData(w, data)
 }
 
+// getServerDiagnostics returns the latest status and subscription diagnostics of the OPC UA servers.
+func (ctl *MeasuresController) getServerDiagnostics(w http.ResponseWriter, r *http.Request) {
+	diagnostics, err := ctl.cache.GetAllServerDiagnostics()
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't obtain the server diagnostics", err)
+		ctl.handleWebError(w, http.StatusInternalServerError, "Couldn't read the server diagnostics from the cache")
+
+		return
+	}
+
+	data, err := json.MarshalIndent(diagnostics, "", "    ")
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't marshal data to JSON", err)
+		ctl.handleWebError(w, http.StatusInternalServerError, "Couldn't marshal data to JSON")
+
+		return
+	}
+
+	ctl.sendData(w, data)
+}
+
 // changeBoundsForParameter changes the alert bounds for the specified parameter.
 func (ctl *MeasuresController) changeBoundsForParameter(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	server := vars["server"]
 	parameter, ok := vars["parameter"]
 
 	if !ok {
@@ -149,9 +210,9 @@ func (ctl *MeasuresController) changeBoundsForParameter(w http.ResponseWriter, r
 		return
 	}
 
-	if _, err := ctl.cache.GetParameterBounds(parameter); err != nil {
+	if _, err := ctl.cache.GetParameterBounds(server, parameter); err != nil {
 		ctl.handleWebError(w, http.StatusNotFound,
-			fmt.Sprintf("Parameter '%s' is not monitored on the server", parameter))
+			fmt.Sprintf("Parameter '%s' is not monitored on the server '%s'", parameter, server))
 
 		return
 	}
@@ -182,7 +243,7 @@ func (ctl *MeasuresController) changeBoundsForParameter(w http.ResponseWriter, r
 		return
 	}
 
-	err = ctl.cache.SetParameterBounds(parameter, bounds)
+	err = ctl.cache.SetParameterBounds(server, parameter, bounds)
 
 	if err != nil {
 		ctl.handleInternalError("Couldn't set bounds for the parameter", err)
@@ -193,24 +254,205 @@ func (ctl *MeasuresController) changeBoundsForParameter(w http.ResponseWriter, r
 	}
 }
 
+// writeParameterValue writes the value of the parameter on the OPC UA server
+// and sends the status of the write to the client. The write is recorded
+// in the audit on behalf of the authenticated user.
+func (ctl *MeasuresController) writeParameterValue(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	server := vars["server"]
+	parameter, ok := vars["parameter"]
+
+	if !ok {
+		ctl.handleWebError(w, http.StatusBadRequest,
+			fmt.Sprint("Parameter is missing", parameter))
+
+		return
+	}
+
+	exists, err := ctl.cache.CheckParameterExists(server, parameter)
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't check if the parameter exists", err)
+		ctl.handleWebError(w, http.StatusInternalServerError, "Couldn't read the parameters from the cache")
+
+		return
+	}
+
+	if !exists {
+		ctl.handleWebError(w, http.StatusNotFound,
+			fmt.Sprintf("Parameter '%s' is not monitored on the server '%s'", parameter, server))
+
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		ctl.handleWebError(w, http.StatusBadRequest, "Couldn't read request body")
+		return
+	}
+
+	var request valueRequest
+	err = json.Unmarshal(body, &request)
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't parse JSON", err)
+		ctl.handleWebError(w, http.StatusBadRequest, "Couldn't parse JSON data")
+
+		return
+	}
+
+	command := data.WriteCommand{
+		Server:    server,
+		Parameter: parameter,
+		User:      authenticatedUser(r),
+	}
+
+	command.Value, ok = valueFromJSON(request.Value)
+
+	if !ok {
+		ctl.handleWebError(w, http.StatusBadRequest, "The value must be a number, a boolean or a string")
+		return
+	}
+
+	result, err := ctl.commander.Write(command)
+	ctl.sendCommandResult(w, result, err)
+}
+
+// callMethod calls the method on the OPC UA server on behalf of the authenticated user
+// and sends its outputs to the client.
+func (ctl *MeasuresController) callMethod(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	method, ok := vars["method"]
+
+	if !ok {
+		ctl.handleWebError(w, http.StatusBadRequest,
+			fmt.Sprint("Method is missing", method))
+
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		ctl.handleWebError(w, http.StatusBadRequest, "Couldn't read request body")
+		return
+	}
+
+	var request callRequest
+	err = json.Unmarshal(body, &request)
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't parse JSON", err)
+		ctl.handleWebError(w, http.StatusBadRequest, "Couldn't parse JSON data")
+
+		return
+	}
+
+	command := data.CallCommand{
+		Server:    request.Server,
+		Method:    method,
+		Arguments: make([]data.Value, len(request.Arguments)),
+		User:      authenticatedUser(r),
+	}
+
+	for i, argument := range request.Arguments {
+		command.Arguments[i], ok = valueFromJSON(argument)
+
+		if !ok {
+			ctl.handleWebError(w, http.StatusBadRequest, "The arguments must be numbers, booleans or strings")
+			return
+		}
+	}
+
+	result, err := ctl.commander.Call(command)
+	ctl.sendCommandResult(w, result, err)
+}
+
+// sendCommandResult sends the result of the command executed by the monitor to the client.
+func (ctl *MeasuresController) sendCommandResult(w http.ResponseWriter, result data.CommandResult, err error) {
+	if err == nats.ErrTimeout {
+		ctl.handleWebError(w, http.StatusGatewayTimeout, "The monitor hasn't replied in time")
+		return
+	}
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't send the command", err)
+		ctl.handleWebError(w, http.StatusBadGateway, "Couldn't send the command to the monitor")
+
+		return
+	}
+
+	if result.Error != "" {
+		ctl.handleWebError(w, http.StatusBadRequest, result.Error)
+		return
+	}
+
+	if !result.IsGood() {
+		ctl.handleWebError(w, http.StatusBadGateway,
+			fmt.Sprintf("The server rejected the command: %s", result.Status))
+
+		return
+	}
+
+	data, err := json.MarshalIndent(result, "", "    ")
+
+	if err != nil {
+		ctl.handleInternalError("Couldn't marshal the object to JSON", err)
+		ctl.handleWebError(w, http.StatusInternalServerError, "Couldn't build a JSON response")
+
+		return
+	}
+
+	ctl.sendData(w, data)
+}
+
+// valueFromJSON converts the decoded JSON scalar to the parameter value.
+func valueFromJSON(raw interface{}) (data.Value, bool) {
+	switch value := raw.(type) {
+	case float64:
+		return data.NewNumber(value), true
+
+	case bool:
+		return data.NewBoolean(value), true
+
+	case string:
+		return data.NewText(value), true
+
+	default:
+		return data.Value{}, false
+	}
+}
+
 // SetupRoutes sets up HTTP routes for the controller.
 func (ctl *MeasuresController) SetupRoutes(router *mux.Router) {
 	router.Use(jsonMiddleware,
 		corsMiddleware,
 		loggingMiddleware(ctl.logger))
 
+	// The commands changing the state of the servers require the authenticated user.
+	authenticated := authMiddleware(ctl.auth, &ctl.controller)
+
 	router.HandleFunc("/measures", ctl.measures)
-	router.HandleFunc("/{parameter:[A-Z][a-z]+}/bounds", ctl.changeBoundsForParameter).Methods("PATCH")
-	router.HandleFunc("/{parameter:[A-Z][a-z]+}/bounds", ctl.getBoundsForParameter).Methods("GET")
+	router.HandleFunc("/{server}/{parameter}/bounds", ctl.changeBoundsForParameter).Methods("PATCH")
+	router.HandleFunc("/{server}/{parameter}/bounds", ctl.getBoundsForParameter).Methods("GET")
+	router.HandleFunc("/{server}/{parameter}/metadata", ctl.getMetadataForParameter).Methods("GET")
+	router.Handle("/{server}/{parameter}/value",
+		authenticated(http.HandlerFunc(ctl.writeParameterValue))).Methods("PUT")
 	router.HandleFunc("/parameters", ctl.getAllParameters).Methods("GET")
+	router.HandleFunc("/diagnostics", ctl.getServerDiagnostics).Methods("GET")
+	router.Handle("/methods/{method}", authenticated(http.HandlerFunc(ctl.callMethod))).Methods("POST")
 }
 
 // NewMeasuresController returns a new measures controller for the monitored parameters.
-func NewMeasuresController(sub *shared.Subscriber, logger *log.Logger, cache *shared.Cache) *MeasuresController {
+func NewMeasuresController(sub *shared.Subscriber, logger *log.Logger, cache *shared.Cache,
+	commander *shared.Commander, auth *Authenticator) *MeasuresController {
 	ctl := new(MeasuresController)
 	ctl.sub = sub
 	ctl.logger = logger
 	ctl.cache = cache
+	ctl.commander = commander
+	ctl.auth = auth
 
 	return ctl
 }
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -24,6 +25,10 @@ var (
 	cacheAddress  string
 	brokerAddress string
 	topic         string
+	commandsTopic string
+	timeout       int
+	tokensPath    string
+	proxies       string
 	launchTimeout int
 )
 
@@ -31,6 +36,12 @@ func parseFlags() {
 	flag.StringVar(&cacheAddress, "cacheaddress", "", "Address and port of the cache server")
 	flag.StringVar(&brokerAddress, "brokerhost", "", "Address of the message broker")
 	flag.StringVar(&topic, "topic", "measures", "Name of the topic to spread measures across the system")
+	flag.StringVar(&commandsTopic, "commands", "commands", "Name of the topic to send commands to the monitor")
+	flag.IntVar(&timeout, "command-timeout", 5, "Time to wait for the reply to a command in seconds")
+	flag.StringVar(&tokensPath, "tokens", "",
+		"File listing the users allowed to send commands with their bearer tokens ('user token' per line)")
+	flag.StringVar(&proxies, "trusted-proxies", "",
+		"Comma-separated IP addresses of the authenticating proxies allowed to set the X-User header")
 	flag.IntVar(&launchTimeout, "launch-timeout", 5, "Time to sleep before starting the application")
 
 	flag.Parse()
@@ -79,8 +90,34 @@ func main() {
 	sub.Start()
 	defer sub.Stop()
 
+	// Create a commander to send commands to the monitor.
+	commander := shared.NewCommander(brokerAddress, commandsTopic, logger, time.Duration(timeout)*time.Second)
+	err = commander.Connect()
+	handleError(logger, "Couldn't connect to the message broker", err)
+	defer commander.CloseConnection()
+
+	// Create an authenticator of the users sending the commands.
+	tokens := make(map[string]string)
+
+	if tokensPath != "" {
+		tokens, err = api.LoadTokens(tokensPath)
+		handleError(logger, "Couldn't load the user tokens", err)
+	}
+
+	trusted := make([]string, 0)
+
+	if proxies != "" {
+		trusted = strings.Split(proxies, ",")
+	}
+
+	auth := api.NewAuthenticator(tokens, trusted)
+
+	if !auth.IsEnabled() {
+		logger.Println("Neither the tokens nor the trusted proxies are set, the commands are rejected")
+	}
+
 	// Create a data controller.
-	measuresController := api.NewMeasuresController(sub, logger, cache)
+	measuresController := api.NewMeasuresController(sub, logger, cache, commander, auth)
 
 	// Assign routing paths.
 	router := mux.NewRouter()